@@ -0,0 +1,162 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Load reports the load of an output channel. A Chann is a Load.
+type Load interface {
+	// Len returns the number of values that are waiting to be
+	// received from the output.
+	Len() int
+	// Cap returns the capacity of the output.
+	Cap() int
+}
+
+// A Balancer picks an output for each value that is distributed by
+// FanoutWith or LBWith. Pick is given the loads of all outputs, which
+// is never empty, and returns the index of the chosen output.
+//
+// The Balancers provided by this package are safe for concurrent use,
+// hence they can be shared among several distributors.
+type Balancer interface {
+	Pick(outs []Load) int
+}
+
+// Randomizer returns a Balancer that picks outputs by the given
+// randomizer, and falls back to rand.Intn if the returned index is
+// out of range.
+func Randomizer(randomizer func(max int) int) Balancer {
+	return randomizerBalancer(randomizer)
+}
+
+type randomizerBalancer func(max int) int
+
+func (f randomizerBalancer) Pick(outs []Load) int {
+	l := len(outs)
+	i := f(l)
+	if i < 0 || i >= l {
+		i = rand.Intn(l)
+	}
+	return i
+}
+
+// RoundRobin returns a Balancer that picks outputs in turn.
+func RoundRobin() Balancer { return &roundRobin{} }
+
+type roundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (b *roundRobin) Pick(outs []Load) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.next % len(outs)
+	b.next = i + 1
+	return i
+}
+
+// WeightedRoundRobin returns a Balancer that picks outputs in turn,
+// in proportion to the given weights. The i-th weight belongs to the
+// i-th output, a missing weight is 1, and a non-positive weight
+// excludes the output unless all weights are non-positive.
+//
+// The picks are spread smoothly, for example, the weights 5, 1, 1
+// give the sequence a, a, b, a, c, a, a rather than a, a, a, a, a, b, c.
+func WeightedRoundRobin(weights ...int) Balancer {
+	return &weightedRoundRobin{weights: weights}
+}
+
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	weights []int
+	current []int
+}
+
+func (b *weightedRoundRobin) Pick(outs []Load) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.current) != len(outs) {
+		b.current = make([]int, len(outs))
+	}
+	best, total := -1, 0
+	for i := range outs {
+		w := 1
+		if i < len(b.weights) {
+			w = b.weights[i]
+		}
+		if w <= 0 {
+			continue
+		}
+		b.current[i] += w
+		total += w
+		if best < 0 || b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return rand.Intn(len(outs))
+	}
+	b.current[best] -= total
+	return best
+}
+
+// LeastLoaded returns a Balancer that picks the output with the least
+// number of waiting values. Ties are broken in favor of the output with
+// the larger capacity, and then in turn.
+func LeastLoaded() Balancer { return &leastLoaded{} }
+
+type leastLoaded struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (b *leastLoaded) Pick(outs []Load) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := len(outs)
+	best, bestLen, bestCap := -1, 0, 0
+	for k := 0; k < l; k++ {
+		i := (b.next + k) % l
+		n, c := outs[i].Len(), outs[i].Cap()
+		if best < 0 || n < bestLen || (n == bestLen && c > bestCap) {
+			best, bestLen, bestCap = i, n, c
+		}
+	}
+	b.next = (best + 1) % l
+	return best
+}
+
+// PowerOfTwoChoices returns a Balancer that picks two outputs at random
+// and chooses the one with fewer waiting values. It is nearly as good
+// as LeastLoaded, but only inspects two outputs for each value.
+func PowerOfTwoChoices() Balancer { return powerOfTwoChoices{} }
+
+type powerOfTwoChoices struct{}
+
+func (powerOfTwoChoices) Pick(outs []Load) int {
+	l := len(outs)
+	if l == 1 {
+		return 0
+	}
+	i := rand.Intn(l)
+	j := rand.Intn(l - 1)
+	if j >= i {
+		j++
+	}
+	if outs[j].Len() < outs[i].Len() {
+		return j
+	}
+	return i
+}
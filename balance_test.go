@@ -0,0 +1,54 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+type load struct{ len, cap int }
+
+func (l load) Len() int { return l.len }
+func (l load) Cap() int { return l.cap }
+
+func pick(b chann.Balancer, outs []chann.Load, n int) []int {
+	got := make([]int, n)
+	for i := range got {
+		got[i] = b.Pick(outs)
+	}
+	return got
+}
+
+func TestBalancer(t *testing.T) {
+	idle := []chann.Load{load{0, 1}, load{0, 1}, load{0, 1}}
+
+	tests := []struct {
+		name string
+		b    chann.Balancer
+		outs []chann.Load
+		want []int
+	}{
+		{"randomizer", chann.Randomizer(func(int) int { return 42 }), []chann.Load{load{}}, []int{0, 0}},
+		{"round-robin", chann.RoundRobin(), idle, []int{0, 1, 2, 0, 1, 2}},
+		{"weighted", chann.WeightedRoundRobin(5, 1, 1), idle, []int{0, 0, 1, 0, 2, 0, 0}},
+		{"weighted-excluded", chann.WeightedRoundRobin(0, 2), idle, []int{1, 2, 1, 1, 2, 1}},
+		{"least-loaded", chann.LeastLoaded(), []chann.Load{load{3, 4}, load{1, 4}, load{2, 4}}, []int{1, 1}},
+		{"least-loaded-ties", chann.LeastLoaded(), []chann.Load{load{1, 1}, load{1, 2}, load{1, 2}}, []int{1, 2, 1}},
+		{"power-of-two", chann.PowerOfTwoChoices(), []chann.Load{load{9, 9}, load{0, 9}}, []int{1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pick(tt.b, tt.outs, len(tt.want))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected picks, got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -49,7 +49,7 @@ import (
 )
 
 // Opt represents an option to configure the created channel. The current possible
-// option is Cap.
+// options are Cap, RateLimit and WithClock.
 type Opt func(*config)
 
 // Cap is the option to configure the capacity of a creating buffer.
@@ -74,6 +74,40 @@ func Cap(n int) Opt {
 	}
 }
 
+// RateLimit is the option to bound the rate at which values can be received
+// from a creating channel. The values are received from Out no faster than
+// r values per second on average, with bursts of up to burst values. The
+// limit applies to any type of channel. After Close, the values that are
+// still held by the channel are received at the limited rate by pending
+// receivers, and are dropped if there is none, as for an unbounded
+// channel, so that a channel that nobody receives from anymore does not
+// keep its values and goroutines alive.
+//
+// The limiter holds the next value to be received, which is not counted
+// by Len. Hence, a send to an unbuffered channel may complete before a
+// receiver is ready, and a buffered channel may hold one more value
+// than its capacity.
+//
+// RateLimit panics if r or burst is not positive.
+func RateLimit(r float64, burst int) Opt {
+	if r <= 0 || burst <= 0 {
+		panic("chann: non-positive rate limit")
+	}
+	return func(s *config) {
+		s.rate = r
+		s.burst = burst
+	}
+}
+
+// WithClock is the option to configure the Clock that measures the time
+// for a creating channel, which is used by RateLimit. The operators of
+// the package that depend on the time, such as Throttle, also use it.
+// By default, the clock of the time package is used, and another clock
+// is mostly useful to control the time in tests.
+func WithClock(c Clock) Opt {
+	return func(s *config) { s.clock = c }
+}
+
 // Chann is a generic channel abstraction that can be either buffered,
 // unbuffered, or unbounded. To create a new channel, use New to allocate
 // one, and use Cap to configure the capacity of the channel.
@@ -82,6 +116,8 @@ type Chann[T any] struct {
 	close   chan struct{}
 	cfg     *config
 	q       []T
+	lim     chan T        // rate limited output, if any
+	quit    chan struct{} // closed by Close if rate limited
 }
 
 // New returns a Chann that may be a buffered, an unbuffered or an
@@ -103,21 +139,21 @@ type Chann[T any] struct {
 // after a send is complete. However, the recipient of an unbounded channel
 // may be available within a bounded time frame after a send is complete.
 //
-// Note that although the input arguments are specified as variadic parameter
-// list, however, the function panics if there is more than one option is
-// provided.
+// To bound the rate of receiving values from the channel, use RateLimit.
+// The options are applied in order, hence if Cap is provided more than
+// once, the last one wins.
 func New[T any](opts ...Opt) *Chann[T] {
 	cfg := &config{
 		cap: -1, len: 0,
 		typ: unbounded,
 	}
 
-	if len(opts) > 1 {
-		panic("chann: too many arguments")
-	}
 	for _, o := range opts {
 		o(cfg)
 	}
+	if cfg.clock == nil {
+		cfg.clock = realClock{}
+	}
 	ch := &Chann[T]{cfg: cfg, close: make(chan struct{})}
 	switch ch.cfg.typ {
 	case unbuffered:
@@ -131,6 +167,11 @@ func New[T any](opts ...Opt) *Chann[T] {
 		ch.out = make(chan T, 16)
 		go ch.unboundedProcessing()
 	}
+	if ch.cfg.rate > 0 {
+		ch.lim = make(chan T)
+		ch.quit = make(chan struct{})
+		go ch.rateProcessing()
+	}
 	return ch
 }
 
@@ -141,7 +182,12 @@ func (ch *Chann[T]) In() chan<- T { return ch.in }
 
 // Out returns the receive channel of the given Chann, which can be used
 // to receive values from the channel.
-func (ch *Chann[T]) Out() <-chan T { return ch.out }
+func (ch *Chann[T]) Out() <-chan T {
+	if ch.lim != nil {
+		return ch.lim
+	}
+	return ch.out
+}
 
 // Close closes the channel gracefully.
 func (ch *Chann[T]) Close() {
@@ -152,6 +198,9 @@ func (ch *Chann[T]) Close() {
 	default:
 		ch.close <- struct{}{}
 	}
+	if ch.quit != nil {
+		close(ch.quit)
+	}
 }
 
 // unboundedProcessing is a processing loop that implements unbounded
@@ -265,4 +314,7 @@ const (
 type config struct {
 	typ      chanType
 	len, cap int64
+	rate     float64
+	burst    int
+	clock    Clock
 }
@@ -0,0 +1,112 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"time"
+)
+
+// Chunk returns a channel that receives the values received from in,
+// grouped by chunks of up to n values. A partial chunk is sent once no
+// value arrives for the duration idle, unless idle is not positive.
+//
+// Once in is closed, the last partial chunk is sent and the returned
+// channel is closed. Once the context is done, the returned channel is
+// closed without sending the partial chunk. The options configure the
+// output channel, and WithClock configures the clock of the operator.
+func Chunk[T any](ctx context.Context, in *Chann[T], n int, idle time.Duration, opts ...Opt) *Chann[[]T] {
+	return ChunkWeighted(ctx, in, n, func(T) int { return 1 }, idle, opts...)
+}
+
+// ChunkWeighted is like Chunk, but caps each chunk by the total weight
+// of its values rather than by their number, for instance, by their
+// size in bytes. A value that weighs more than max on its own is sent
+// as a chunk by itself.
+func ChunkWeighted[T any](ctx context.Context, in *Chann[T], max int, weight func(T) int, idle time.Duration, opts ...Opt) *Chann[[]T] {
+	if max <= 0 {
+		panic("chann: non-positive chunk size")
+	}
+	out, clock := newOutput[[]T](opts)
+	go func() {
+		defer out.Close()
+
+		var (
+			chunk   []T
+			total   int
+			timer   Timer
+			expired <-chan time.Time
+		)
+		flush := func() bool {
+			vs := chunk
+			chunk, total = nil, 0
+			select {
+			case out.In() <- vs:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if len(chunk) > 0 {
+						flush()
+					}
+					return
+				}
+				w := weight(v)
+				if len(chunk) > 0 && total+w > max && !flush() {
+					return
+				}
+				chunk = append(chunk, v)
+				total += w
+				if total >= max {
+					if !flush() {
+						return
+					}
+					continue
+				}
+				if idle > 0 {
+					if timer == nil {
+						timer = clock.NewTimer(idle)
+					} else {
+						resetTimer(timer, expired != nil, idle)
+					}
+					expired = timer.C()
+				}
+			case <-expired:
+				expired = nil
+				if len(chunk) > 0 && !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// resetTimer changes the timer to fire after the duration d. If the
+// timer may have fired without being received, its channel is drained
+// first, as required by time.Timer.Reset.
+func resetTimer(t Timer, pending bool, d time.Duration) {
+	if !t.Stop() && pending {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+	t.Reset(d)
+}
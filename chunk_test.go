@@ -0,0 +1,54 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestChunk(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.Chunk(context.Background(), in, 3, time.Second, chann.WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		in.In() <- i
+	}
+	expectWindow(t, out, []int{0, 1, 2})
+
+	// The partial chunk is sent once the input is idle.
+	in.In() <- 3
+	clock.Wait(3) // NewTimer for 0, and Reset for 1 and 3
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{3})
+
+	in.In() <- 4
+	in.Close()
+	expectWindow(t, out, []int{4})
+	expectClosed(t, out)
+}
+
+func TestChunkWeighted(t *testing.T) {
+	in := chann.New[int](chann.Cap(0))
+	out := chann.ChunkWeighted(context.Background(), in, 10, func(v int) int { return v }, 0)
+	go func() {
+		for _, v := range []int{3, 4, 5, 12, 1, 9, 2} {
+			in.In() <- v
+		}
+		in.Close()
+	}()
+	expectWindow(t, out, []int{3, 4})
+	expectWindow(t, out, []int{5})
+	expectWindow(t, out, []int{12})
+	expectWindow(t, out, []int{1, 9})
+	expectWindow(t, out, []int{2})
+	expectClosed(t, out)
+}
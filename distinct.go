@@ -0,0 +1,125 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// DistinctOpt represents an option to configure Distinct, which is
+// either DistinctMaxEntries, DistinctTTL, or an Opt that configures the
+// output channel, such as Cap or WithClock.
+type DistinctOpt interface {
+	applyDistinct(*distinctConfig)
+}
+
+type distinctConfig struct {
+	max  int
+	ttl  time.Duration
+	opts []Opt
+}
+
+type distinctOpt func(*distinctConfig)
+
+func (o distinctOpt) applyDistinct(cfg *distinctConfig) { o(cfg) }
+func (o Opt) applyDistinct(cfg *distinctConfig)         { cfg.opts = append(cfg.opts, o) }
+
+// DistinctMaxEntries bounds the number of keys remembered by Distinct.
+// Once the bound is reached, the least recently seen key is forgotten,
+// so that a value of that key is not dropped anymore.
+func DistinctMaxEntries(n int) DistinctOpt {
+	return distinctOpt(func(cfg *distinctConfig) { cfg.max = n })
+}
+
+// DistinctTTL bounds the duration for which Distinct remembers a key
+// since its first value. A value of the key is not dropped anymore
+// after the duration, as measured by the clock of WithClock.
+func DistinctTTL(d time.Duration) DistinctOpt {
+	return distinctOpt(func(cfg *distinctConfig) { cfg.ttl = d })
+}
+
+// Distinct returns a channel that receives the values received from
+// in, except the values whose key has been seen before, as returned by
+// key. The remembered keys are bounded by DistinctMaxEntries and
+// DistinctTTL, and all keys are remembered if neither is provided.
+//
+// The returned channel is closed once in is closed or the context is
+// done, as for the stream operators.
+func Distinct[T any, K comparable](ctx context.Context, in *Chann[T], key func(T) K, opts ...DistinctOpt) *Chann[T] {
+	var cfg distinctConfig
+	for _, o := range opts {
+		o.applyDistinct(&cfg)
+	}
+	clock := clockOf(cfg.opts)
+
+	type entry struct {
+		key      K
+		seen     time.Time
+		lru, age *list.Element
+	}
+	var (
+		lru  = list.New() // most recently seen keys in front
+		age  = list.New() // oldest keys in front, as they expire first
+		keys = map[K]*entry{}
+	)
+	forget := func(e *entry) {
+		lru.Remove(e.lru)
+		age.Remove(e.age)
+		delete(keys, e.key)
+	}
+	// seen reports whether the key has been seen before, and remembers
+	// the key otherwise. The expired keys are forgotten before the
+	// least recently seen one, so that no live key is evicted while an
+	// expired key is still remembered.
+	seen := func(k K) bool {
+		var now time.Time
+		if cfg.ttl > 0 {
+			now = clock.Now()
+			for f := age.Front(); f != nil && now.Sub(f.Value.(*entry).seen) >= cfg.ttl; f = age.Front() {
+				forget(f.Value.(*entry))
+			}
+		}
+		if e, ok := keys[k]; ok {
+			lru.MoveToFront(e.lru)
+			return true
+		}
+		e := &entry{key: k, seen: now}
+		e.lru, e.age = lru.PushFront(e), age.PushBack(e)
+		keys[k] = e
+		if cfg.max > 0 && lru.Len() > cfg.max {
+			forget(lru.Back().Value.(*entry))
+		}
+		return false
+	}
+	return stream(ctx, in, cfg.opts, func(v T, emit func(T) bool) bool {
+		return seen(key(v)) || emit(v)
+	}, nil)
+}
+
+// DistinctUntilChanged returns a channel that receives the values
+// received from in, except the values whose key, as returned by key,
+// equals the key of the previous value.
+//
+// The returned channel is closed once in is closed or the context is
+// done, and the options configure the output channel, as for the
+// stream operators.
+func DistinctUntilChanged[T any, K comparable](ctx context.Context, in *Chann[T], key func(T) K, opts ...Opt) *Chann[T] {
+	var (
+		last  K
+		first = true
+	)
+	return stream(ctx, in, opts, func(v T, emit func(T) bool) bool {
+		k := key(v)
+		if !first && k == last {
+			return true
+		}
+		first, last = false, k
+		return emit(v)
+	}, nil)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestDistinct(t *testing.T) {
+	identity := func(v int) int { return v }
+	tests := []struct {
+		opts []chann.DistinctOpt
+		want []int
+	}{
+		{nil, []int{1, 2, 3}},
+		{[]chann.DistinctOpt{chann.Cap(-1)}, []int{1, 2, 3}},
+		// The least recently seen key is forgotten.
+		{[]chann.DistinctOpt{chann.DistinctMaxEntries(2)}, []int{1, 2, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		in := sendAll(1, 2, 1, 3, 2, 1)
+		out := chann.Distinct(context.Background(), in, identity, tt.opts...)
+		if got := chann.Collect(out); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("unexpected values, got %v want %v", got, tt.want)
+		}
+	}
+}
+
+func TestDistinctTTL(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[string](chann.Cap(0))
+	out := chann.Distinct(context.Background(), in, func(s string) byte { return s[0] },
+		chann.DistinctTTL(time.Second), chann.WithClock(clock), chann.DistinctMaxEntries(10))
+	expect := func(want string) {
+		t.Helper()
+		if got := <-out.Out(); got != want {
+			t.Fatalf("unexpected value, got %v want %v", got, want)
+		}
+	}
+
+	in.In() <- "a1"
+	expect("a1")
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- "a2"
+	in.In() <- "b1"
+	expect("b1")
+
+	// The key is forgotten a second after its first value.
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- "a3"
+	expect("a3")
+	in.In() <- "b2"
+	in.Close()
+	expectClosed(t, out)
+
+	// A key seen again is not forgotten in favor of an expired key,
+	// when the number of keys is bounded as well.
+	clock = newFakeClock()
+	in = chann.New[string](chann.Cap(0))
+	out = chann.Distinct(context.Background(), in, func(s string) string { return s },
+		chann.DistinctTTL(time.Second), chann.WithClock(clock), chann.DistinctMaxEntries(2))
+	in.In() <- "A"
+	expect("A")
+	clock.Advance(100 * time.Millisecond)
+	in.In() <- "B"
+	expect("B")
+	clock.Advance(100 * time.Millisecond)
+	in.In() <- "A"
+	clock.Wait(3)
+	clock.Advance(850 * time.Millisecond)
+	in.In() <- "C"
+	expect("C")
+	clock.Advance(10 * time.Millisecond)
+	in.In() <- "B"
+	in.Close()
+	expectClosed(t, out)
+}
+
+func TestDistinctUntilChanged(t *testing.T) {
+	in := sendAll(1, 1, 2, 2, 2, 1, 3, 3)
+	out := chann.DistinctUntilChanged(context.Background(), in, func(v int) int { return v })
+	want := []int{1, 2, 1, 3}
+	if got := chann.Collect(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values, got %v want %v", got, want)
+	}
+}
@@ -11,3 +11,17 @@ package chann
 func IsClosed[T any](ch *Chann[T]) bool {
 	return ch.isClosed()
 }
+
+// HashRing returns the node that each key belongs to on a hash ring
+// of the given nodes. This function is only exported for testing.
+func HashRing(nodes []string, keys []string) []string {
+	r := newHashRing(defaultReplicas, func(n string) string { return n })
+	for _, n := range nodes {
+		r.add(n)
+	}
+	got := make([]string, len(keys))
+	for i, k := range keys {
+		got[i] = r.get(hashKey(k))
+	}
+	return got
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"sync"
+)
+
+// A Handle controls the goroutines that are started by a helper such
+// as FaninContext, FanoutContext or LBContext. It can be used to stop
+// the goroutines and to wait for them to finish.
+type Handle struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	done   chan struct{}
+}
+
+// newHandle returns a new Handle, and a context derived from ctx that
+// is canceled when the Handle is stopped.
+func newHandle(ctx context.Context) (context.Context, *Handle) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &Handle{cancel: cancel, done: make(chan struct{})}
+}
+
+// Stop tells the goroutines to stop, and returns without waiting for
+// them. It is safe to call Stop more than once.
+func (h *Handle) Stop() { h.cancel() }
+
+// Wait waits for the goroutines to finish, and returns the first error
+// they returned, which is the error of the context if they were stopped
+// before their inputs were closed.
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+// Done returns a channel that is closed when the goroutines finish.
+func (h *Handle) Done() <-chan struct{} { return h.done }
+
+// goFunc runs the given function in a new goroutine that belongs to
+// the Handle. It must not be called after start.
+func (h *Handle) goFunc(fn func() error) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := fn(); err != nil {
+			h.once.Do(func() { h.err = err })
+		}
+	}()
+}
+
+// start waits for all goroutines of the Handle in the background, and
+// then calls finish, if not nil, before marking the Handle as done.
+func (h *Handle) start(finish func()) {
+	go func() {
+		h.wg.Wait()
+		if finish != nil {
+			finish()
+		}
+		h.cancel()
+		close(h.done)
+	}()
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+//go:build go1.23
+
+package chann
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the values received from the channel.
+// The iteration ends when the channel is closed and drained, or when
+// the loop body stops early.
+//
+//	for v := range ch.All() {
+//		// ...
+//	}
+//
+// Stopping early does not close the channel, and the remaining values
+// stay available to other receivers.
+func (ch *Chann[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range ch.Out() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the values sent by the Sender. The
+// iteration ends when the Sender is closed.
+//
+// If the loop body stops early, the Receiver is stopped, so that the
+// Sender is told that no more values will be received.
+func (r *Receiver[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := r.Next()
+			if !ok {
+				return
+			}
+			if !yield(v) {
+				r.Stop()
+				return
+			}
+		}
+	}
+}
+
+// FromSeq returns a channel that receives the values produced by seq,
+// and is closed once seq is exhausted or the context is done. The
+// options configure the channel, which is unbuffered by default.
+//
+// The values are produced by a separate goroutine, which waits for
+// each value to be received. If the consumer stops early, the context
+// should be canceled, so that seq is stopped as if the loop over it
+// ended early, and the goroutine exits.
+func FromSeq[T any](ctx context.Context, seq iter.Seq[T], opts ...Opt) *Chann[T] {
+	ch, _ := newOutput[T](opts)
+	go func() {
+		defer ch.Close()
+		for v := range seq {
+			select {
+			case ch.In() <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+//go:build go1.23
+
+package chann_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestChannAll(t *testing.T) {
+	ch := chann.New[int]()
+	for i := 0; i < 10; i++ {
+		ch.In() <- i
+	}
+	ch.Close()
+
+	sum := 0
+	for v := range ch.All() {
+		sum += v
+	}
+	if sum != 45 {
+		t.Fatalf("unexpected sum, got %v want %v", sum, 45)
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	want := []int{1, 2, 3, 4, 5}
+	got := chann.Collect(chann.FromSeq(context.Background(), slices.Values(want)))
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected values, got %v want %v", got, want)
+	}
+
+	// An endless sequence is stopped once the context is canceled.
+	stopped := make(chan struct{})
+	naturals := func(yield func(int) bool) {
+		defer close(stopped)
+		for i := 0; yield(i); i++ {
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := chann.FromSeq(ctx, naturals)
+	for i := 0; i < 3; i++ {
+		if v := <-ch.Out(); v != i {
+			t.Fatalf("unexpected value, got %v want %v", v, i)
+		}
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("sequence was not stopped after canceling the context")
+	}
+	for range ch.Out() {
+	}
+}
+
+func TestReceiverAll(t *testing.T) {
+	s, r := chann.Ranger[int]()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; s.Send(i); i++ {
+		}
+		s.Close()
+	}()
+
+	n := 0
+	for v := range r.All() {
+		if v != n {
+			t.Fatalf("unexpected value, got %v want %v", v, n)
+		}
+		n++
+		if n == 10 {
+			break
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("sender was not stopped after breaking the loop")
+	}
+}
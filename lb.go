@@ -7,49 +7,592 @@
 package chann
 
 import (
-	"math/rand"
-	"sync"
+	"container/heap"
+	"context"
+	"fmt"
+	"sync/atomic"
 )
 
 // Fanin provides a generic fan-in functionality for variadic channels.
 func Fanin[T any](chans ...*Chann[T]) *Chann[T] {
-	buf := 0
-	for _, ch := range chans {
-		if ch.Len() > buf {
-			buf = ch.Len()
-		}
-	}
-	out := New[T](Cap(buf))
-	wg := sync.WaitGroup{}
-	wg.Add(len(chans))
+	out, _ := FaninContext(context.Background(), chans...)
+	return out
+}
+
+// FaninContext is like Fanin, but stops receiving from the inputs and
+// closes the returned channel as soon as the context is done or the
+// returned Handle is stopped. The returned channel is unbuffered, as
+// for FaninFair and MergeSorted.
+func FaninContext[T any](ctx context.Context, chans ...*Chann[T]) (*Chann[T], *Handle) {
+	out := New[T](Cap(0))
+	ctx, h := newHandle(ctx)
 	for _, ch := range chans {
-		go func(ch *Chann[T]) {
-			for v := range ch.Out() {
-				out.In() <- v
+		ch := ch
+		h.goFunc(func() error {
+			for {
+				select {
+				case v, ok := <-ch.Out():
+					if !ok {
+						return nil
+					}
+					select {
+					case out.In() <- v:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
-			wg.Done()
-		}(ch)
+		})
 	}
+	h.start(out.Close)
+	return out, h
+}
+
+// FaninFair is like Fanin, but receives from the ready input channels
+// in turn, hence a busy input cannot starve the others. The returned
+// channel is unbuffered, and is closed when all inputs are closed.
+func FaninFair[T any](chans ...*Chann[T]) *Chann[T] {
+	out := New[T](Cap(0))
 	go func() {
-		wg.Wait()
+		open := append([]*Chann[T](nil), chans...)
+		next := 0
+		for len(open) > 0 {
+			// Poll the inputs in turn, starting from the one after
+			// the input that was last received from, and only block
+			// if none of them is ready.
+			var (
+				i         int
+				v         T
+				ok, ready bool
+			)
+			for k := 0; k < len(open) && !ready; k++ {
+				i = (next + k) % len(open)
+				select {
+				case v, ok = <-open[i].Out():
+					ready = true
+				default:
+				}
+			}
+			if !ready {
+				i, v, ok = Select(context.Background(), open...)
+			}
+			if !ok {
+				open = append(open[:i], open[i+1:]...)
+				next = i
+				continue
+			}
+			out.In() <- v
+			next = i + 1
+		}
 		out.Close()
 	}()
 	return out
 }
 
+// MergeSorted merges the values received from the given input channels,
+// each of which must be sorted by less, into a single sorted channel.
+// Values that are equal are sent in the order of their inputs. The
+// returned channel is unbuffered, and is closed when all inputs are
+// closed.
+//
+// MergeSorted has to wait for a value from every input that is not yet
+// closed before it can send the smallest one, hence a stalled input
+// stalls the output.
+func MergeSorted[T any](less func(a, b T) bool, chans ...*Chann[T]) *Chann[T] {
+	out := New[T](Cap(0))
+	go func() {
+		h := &mergeHeap[T]{less: less}
+		for i, ch := range chans {
+			if v, ok := <-ch.Out(); ok {
+				h.items = append(h.items, mergeItem[T]{v: v, src: i})
+			}
+		}
+		heap.Init(h)
+		for h.Len() > 0 {
+			it := h.items[0]
+			out.In() <- it.v
+			if v, ok := <-chans[it.src].Out(); ok {
+				h.items[0].v = v
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+		out.Close()
+	}()
+	return out
+}
+
+// mergeItem is the head value of an input of MergeSorted.
+type mergeItem[T any] struct {
+	v   T
+	src int
+}
+
+// mergeHeap is a min-heap of the head values of the inputs of
+// MergeSorted, which implements heap.Interface.
+type mergeHeap[T any] struct {
+	less  func(a, b T) bool
+	items []mergeItem[T]
+}
+
+func (h *mergeHeap[T]) Len() int { return len(h.items) }
+func (h *mergeHeap[T]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.v, b.v) {
+		return true
+	}
+	if h.less(b.v, a.v) {
+		return false
+	}
+	return a.src < b.src
+}
+func (h *mergeHeap[T]) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap[T]) Push(x any)    { h.items = append(h.items, x.(mergeItem[T])) }
+func (h *mergeHeap[T]) Pop() any {
+	n := len(h.items)
+	it := h.items[n-1]
+	h.items = h.items[:n-1]
+	return it
+}
+
+// OverflowPolicy decides what happens to a value that is sent to an
+// output channel that is not ready to receive it.
+type OverflowPolicy int
+
+const (
+	// Spill queues the value without bound until the output is ready,
+	// hence a slow output never blocks the others.
+	Spill OverflowPolicy = iota
+	// Block waits until the output is ready, hence a slow output
+	// blocks the distribution of further values.
+	Block
+	// Drop discards the value if the output is not ready.
+	Drop
+	// Disconnect closes the output if it is not ready, and discards
+	// the value as well as all further values for that output.
+	Disconnect
+)
+
 // Fanout provides a generic fan-out functionality for variadic channels.
+// It is equivalent to FanoutWith using the Spill policy and a Balancer
+// from Randomizer.
 func Fanout[T any](randomizer func(max int) int, in *Chann[T], outs ...*Chann[T]) {
-	l := len(outs)
+	FanoutWith(Spill, Randomizer(randomizer), in, outs...)
+}
+
+// FanoutWith distributes the values received from in to the given
+// output channels, and returns when in is closed. For each value, the
+// output is picked by the Balancer, and a slow output is handled
+// according to the policy.
+//
+// Each output is fed by a dedicated goroutine, hence the values that
+// are sent to the same output keep their order. The load of an output
+// seen by the Balancer includes the values that are spilled for it.
+// Once in is closed and all values are delivered, every output is
+// closed.
+func FanoutWith[T any](policy OverflowPolicy, b Balancer, in *Chann[T], outs ...*Chann[T]) {
+	fanout(context.Background(), policy, in, outs, balance[T](b), false)
+}
+
+// FanoutContext is like FanoutWith, but returns immediately with a
+// Handle, whose Wait returns once all values are delivered and all
+// outputs are closed. As soon as the context is done or the Handle is
+// stopped, the values that are not yet delivered are discarded, and
+// all outputs are closed.
+func FanoutContext[T any](ctx context.Context, policy OverflowPolicy, b Balancer, in *Chann[T], outs ...*Chann[T]) *Handle {
+	ctx, h := newHandle(ctx)
+	h.goFunc(func() error {
+		return fanout(ctx, policy, in, outs, balance[T](b), true)
+	})
+	h.start(nil)
+	return h
+}
+
+// balance returns a function that picks outputs by the Balancer.
+func balance[T any](b Balancer) func(v T, loads []Load) int {
+	return func(_ T, loads []Load) int {
+		i := b.Pick(loads)
+		if i < 0 || i >= len(loads) {
+			panic("chann: balancer picked an out-of-range output")
+		}
+		return i
+	}
+}
+
+// FanoutByKey distributes the values received from in to the given
+// output channels, and returns when in is closed. All values with the
+// same key are sent to the same output, and keep their order.
+//
+// The outputs are chosen by consistent hashing of their addresses in
+// memory, hence the placement of the keys differs between processes.
+// To add or remove outputs at runtime, such that only the keys of
+// those outputs are remapped, use NewKeyedLBGroup.
+//
+// Once in is closed and all values are delivered, every output is
+// closed. A slow output is handled by the Spill policy.
+func FanoutByKey[T any, K comparable](in *Chann[T], key func(T) K, outs ...*Chann[T]) {
+	ring := newHashRing(defaultReplicas, func(i int) string {
+		return fmt.Sprintf("%p", outs[i])
+	})
+	for i := range outs {
+		ring.add(i)
+	}
+	fanout(context.Background(), Spill, in, outs, func(v T, _ []Load) int {
+		return ring.get(hashKey(key(v)))
+	}, false)
+}
+
+// Broadcast sends every value received from in to all the given output
+// channels, and returns when in is closed. It is equivalent to
+// BroadcastWith using the Spill policy for all outputs.
+func Broadcast[T any](in *Chann[T], outs ...*Chann[T]) {
+	BroadcastWith(nil, in, outs...)
+}
+
+// BroadcastWith sends every value received from in to all the given
+// output channels, and returns when in is closed. A slow output is
+// handled according to its own policy, where the i-th policy belongs
+// to the i-th output, and a missing policy is Spill. Hence, a stalled
+// output can only hold up the others if its policy is Block.
+//
+// Each output is fed by a dedicated goroutine, hence the values keep
+// their order. Once in is closed and all values are delivered, every
+// output that is not yet disconnected is closed.
+func BroadcastWith[T any](policies []OverflowPolicy, in *Chann[T], outs ...*Chann[T]) {
+	fwds := make([]*forwarder[T], len(outs))
+	for i, out := range outs {
+		policy := Spill
+		if i < len(policies) {
+			policy = policies[i]
+		}
+		fwds[i] = newForwarder(policy, out)
+	}
 	for v := range in.Out() {
-		i := randomizer(l)
-		if i < 0 || i > l {
-			i = rand.Intn(l)
+		for _, f := range fwds {
+			f.send(v)
+		}
+	}
+	for _, f := range fwds {
+		f.close()
+	}
+}
+
+// Tee returns two channels that both receive every value received
+// from in, and are closed when in is closed. Both channels are
+// unbuffered, and the values for a channel that is not ready are
+// queued without bound, as Broadcast does.
+func Tee[T any](in *Chann[T]) (*Chann[T], *Chann[T]) {
+	a, b := New[T](Cap(0)), New[T](Cap(0))
+	go Broadcast(in, a, b)
+	return a, b
+}
+
+// Partition returns two channels, where match receives the values from
+// in that satisfy the predicate, and rest receives the others. Both
+// channels are unbuffered, and the values for a channel that is not
+// ready are queued without bound. Both channels are closed once in is
+// closed and all values are delivered.
+func Partition[T any](in *Chann[T], pred func(T) bool) (match, rest *Chann[T]) {
+	match, rest = New[T](Cap(0)), New[T](Cap(0))
+	go fanout(context.Background(), Spill, in, []*Chann[T]{match, rest}, func(v T, _ []Load) int {
+		if pred(v) {
+			return 0
+		}
+		return 1
+	}, false)
+	return match, rest
+}
+
+// Route sends each value received from in to the output channel of
+// its class, or to fallback if there is no output for the class, and
+// returns when in is closed. If fallback is nil, such values are
+// discarded. The same channel may serve more than one class.
+//
+// Each output is fed by a dedicated goroutine, hence the values keep
+// their order, and a slow output is handled by the Spill policy. Once
+// in is closed and all values are delivered, every output and fallback
+// are closed.
+func Route[T any, K comparable](in *Chann[T], classify func(T) K, outs map[K]*Chann[T], fallback *Chann[T]) {
+	var chans []*Chann[T]
+	index := map[*Chann[T]]int{}
+	add := func(ch *Chann[T]) int {
+		i, ok := index[ch]
+		if !ok {
+			i = len(chans)
+			index[ch] = i
+			chans = append(chans, ch)
+		}
+		return i
+	}
+	classes := make(map[K]int, len(outs))
+	for k, ch := range outs {
+		classes[k] = add(ch)
+	}
+	other := -1
+	if fallback != nil {
+		other = add(fallback)
+	}
+	fanout(context.Background(), Spill, in, chans, func(v T, _ []Load) int {
+		if i, ok := classes[classify(v)]; ok {
+			return i
+		}
+		return other
+	}, false)
+}
+
+// fanout distributes the values received from in to the outputs
+// picked by the given function, and closes all outputs once in is
+// closed and all values are delivered. A value is discarded if the
+// picked index is negative. If wait is true, fanout returns
+// after all values are delivered, otherwise it returns once in is
+// closed. If the context is done first, the values that are not yet
+// delivered are discarded, all outputs are closed, and fanout returns
+// the error of the context.
+func fanout[T any](ctx context.Context, policy OverflowPolicy, in *Chann[T], outs []*Chann[T], pick func(v T, loads []Load) int, wait bool) error {
+	fwds := make([]*forwarder[T], len(outs))
+	loads := make([]Load, len(outs))
+	for i, out := range outs {
+		fwds[i] = newForwarder(policy, out)
+		loads[i] = fwds[i]
+	}
+	stop := func() error {
+		for _, f := range fwds {
+			f.detach()
+		}
+		return ctx.Err()
+	}
+
+	for {
+		select {
+		case v, ok := <-in.Out():
+			if !ok {
+				for _, f := range fwds {
+					f.close()
+				}
+				if !wait {
+					return nil
+				}
+				for _, f := range fwds {
+					select {
+					case <-f.done:
+					case <-ctx.Done():
+						return stop()
+					}
+				}
+				return nil
+			}
+			i := pick(v, loads)
+			if i < 0 {
+				continue
+			}
+			if !fwds[i].sendContext(ctx, v) {
+				return stop()
+			}
+		case <-ctx.Done():
+			return stop()
+		}
+	}
+}
+
+// A forwarder sends values to an output channel by a dedicated
+// goroutine. A value that the output is not ready to receive is
+// handled according to the policy.
+type forwarder[T any] struct {
+	pending int64 // accessed atomically, keep it 64-bit aligned
+	policy  OverflowPolicy
+	src     chan T
+	out     *Chann[T]
+	detachc chan chan []T
+	done    chan struct{}
+}
+
+// newForwarder returns a forwarder to the given output channel,
+// and starts its goroutine.
+func newForwarder[T any](policy OverflowPolicy, out *Chann[T]) *forwarder[T] {
+	return newQueuedForwarder(policy, out, nil)
+}
+
+// newQueuedForwarder is like newForwarder, but the forwarder starts
+// with the given values to deliver before any value that is sent to
+// it, regardless of the policy.
+func newQueuedForwarder[T any](policy OverflowPolicy, out *Chann[T], q []T) *forwarder[T] {
+	f := &forwarder[T]{
+		pending: int64(len(q)),
+		policy:  policy,
+		src:     make(chan T),
+		out:     out,
+		detachc: make(chan chan []T),
+		done:    make(chan struct{}),
+	}
+	go f.run(q)
+	return f
+}
+
+// send hands over the given value to the forwarder.
+func (f *forwarder[T]) send(v T) {
+	atomic.AddInt64(&f.pending, 1)
+	f.src <- v
+}
+
+// sendContext is like send, but gives up as soon as the context is
+// done. It reports whether the value was handed over.
+func (f *forwarder[T]) sendContext(ctx context.Context, v T) bool {
+	atomic.AddInt64(&f.pending, 1)
+	select {
+	case f.src <- v:
+		return true
+	case <-ctx.Done():
+		atomic.AddInt64(&f.pending, -1)
+		return false
+	}
+}
+
+// sendUntil is like send, but gives up once the stop channel is
+// closed. It reports whether the value was handed over.
+func (f *forwarder[T]) sendUntil(v T, stop <-chan struct{}) bool {
+	atomic.AddInt64(&f.pending, 1)
+	select {
+	case f.src <- v:
+		return true
+	case <-stop:
+		atomic.AddInt64(&f.pending, -1)
+		return false
+	}
+}
+
+// trySend is like send, but gives up once the forwarder is finished,
+// for instance, because it is detached. It reports whether the value
+// was handed over.
+func (f *forwarder[T]) trySend(v T) bool {
+	atomic.AddInt64(&f.pending, 1)
+	select {
+	case f.src <- v:
+		return true
+	case <-f.done:
+		atomic.AddInt64(&f.pending, -1)
+		return false
+	}
+}
+
+// close tells the forwarder that no more values will be sent. The
+// forwarder closes the output after all values are delivered.
+func (f *forwarder[T]) close() { close(f.src) }
+
+// detach stops the forwarder without delivering the values that it
+// holds, closes the output, and returns the undelivered values. If the
+// forwarder is already finished, detach returns nil. It must not be
+// called concurrently with send, but may be with trySend.
+func (f *forwarder[T]) detach() []T {
+	rc := make(chan []T)
+	select {
+	case f.detachc <- rc:
+		return <-rc
+	case <-f.done:
+		return nil
+	}
+}
+
+// Len returns the number of values that are waiting to be received
+// from the output, including the values held by the forwarder.
+func (f *forwarder[T]) Len() int {
+	return int(atomic.LoadInt64(&f.pending)) + f.out.Len()
+}
+
+// Cap returns the capacity of the output.
+func (f *forwarder[T]) Cap() int { return f.out.Cap() }
+
+// run is the processing loop of the forwarder, which starts with the
+// given values to deliver.
+func (f *forwarder[T]) run(q []T) {
+	defer close(f.done)
+
+	var (
+		nilT         T
+		disconnected bool
+	)
+	src := f.src
+	for src != nil || len(q) > 0 {
+		var (
+			recv <-chan T
+			send chan<- T
+			head T
+		)
+		if src != nil && (f.policy == Spill || len(q) == 0) {
+			recv = src
 		}
-		go func(v T) { outs[i].In() <- v }(v)
+		if len(q) > 0 {
+			send, head = f.out.In(), q[0]
+		}
+		select {
+		case v, ok := <-recv:
+			if !ok {
+				src = nil
+				continue
+			}
+			if f.policy == Spill || f.policy == Block {
+				q = append(q, v)
+				continue
+			}
+			atomic.AddInt64(&f.pending, -1)
+			if disconnected {
+				continue
+			}
+			select {
+			case f.out.In() <- v:
+			default:
+				if f.policy == Disconnect {
+					disconnected = true
+					f.out.Close()
+				}
+			}
+		case send <- head:
+			atomic.AddInt64(&f.pending, -1)
+			q[0] = nilT
+			q = q[1:]
+		case rc := <-f.detachc:
+			if !disconnected {
+				f.out.Close()
+			}
+			rc <- q
+			return
+		}
+	}
+	if !disconnected {
+		f.out.Close()
 	}
 }
 
 // LB load balances the given input channels to the output channels.
+// It is equivalent to LBWith using the Spill policy and a Balancer
+// from Randomizer.
 func LB[T any](randomizer func(max int) int, ins []*Chann[T], outs []*Chann[T]) {
-	Fanout(randomizer, Fanin(ins...), outs...)
+	LBWith(Spill, Randomizer(randomizer), ins, outs)
+}
+
+// LBWith load balances the given input channels to the output channels,
+// using the given policy and Balancer as FanoutWith does. For instance,
+// to send each value to the least busy worker:
+//
+//	chann.LBWith(chann.Block, chann.LeastLoaded(), ins, workers)
+func LBWith[T any](policy OverflowPolicy, b Balancer, ins []*Chann[T], outs []*Chann[T]) {
+	FanoutWith(policy, b, Fanin(ins...), outs...)
+}
+
+// LBContext is like LBWith, but returns immediately with a Handle,
+// whose Wait returns once all inputs are closed, all values are
+// delivered and all outputs are closed. As soon as the context is done
+// or the Handle is stopped, LBContext stops receiving from the inputs,
+// discards the values that are not yet delivered, and closes all
+// outputs.
+func LBContext[T any](ctx context.Context, policy OverflowPolicy, b Balancer, ins []*Chann[T], outs []*Chann[T]) *Handle {
+	ctx, h := newHandle(ctx)
+	in, fanin := FaninContext(ctx, ins...)
+	fanout := FanoutContext(ctx, policy, b, in, outs...)
+	h.goFunc(fanin.Wait)
+	h.goFunc(fanout.Wait)
+	h.start(nil)
+	return h
 }
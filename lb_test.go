@@ -7,28 +7,34 @@
 package chann_test
 
 import (
+	"context"
 	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.design/x/chann"
 )
 
-func getInputChan() *chann.Chann[int] {
-	input := chann.New[int](chann.Cap(20))
-	numbers := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
-	go func() {
-		for num := range numbers {
-			input.In() <- num
-		}
-		input.Close()
-	}()
-	return input
+// numbers are the values of the input channels of the tests.
+var numbers = []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+// sendAll returns a closed channel that holds the given values.
+func sendAll[T any](vs ...T) *chann.Chann[T] {
+	ch := chann.New[T](chann.Cap(len(vs)))
+	for _, v := range vs {
+		ch.In() <- v
+	}
+	ch.Close()
+	return ch
 }
 
 func TestFanin(t *testing.T) {
 	chs := make([]*chann.Chann[int], 10)
 	for i := 0; i < 10; i++ {
-		chs[i] = getInputChan()
+		chs[i] = sendAll(numbers...)
 	}
 
 	out := chann.Fanin(chs...)
@@ -44,7 +50,7 @@ func TestFanin(t *testing.T) {
 func TestLB(t *testing.T) {
 	ins := make([]*chann.Chann[int], 10)
 	for i := 0; i < 10; i++ {
-		ins[i] = getInputChan()
+		ins[i] = sendAll(numbers...)
 	}
 	outs := make([]*chann.Chann[int], 10)
 	for i := 0; i < 10; i++ {
@@ -52,3 +58,410 @@ func TestLB(t *testing.T) {
 	}
 	chann.LB(func(m int) int { return rand.Intn(m) }, ins, outs)
 }
+
+func TestFanout(t *testing.T) {
+	for _, policy := range []chann.OverflowPolicy{chann.Spill, chann.Block} {
+		in := chann.New[int](chann.Cap(0))
+		outs := []*chann.Chann[int]{
+			chann.New[int](chann.Cap(0)),
+			chann.New[int](chann.Cap(1)),
+			chann.New[int](chann.Cap(2)),
+		}
+		go func() {
+			for i := 0; i < 300; i++ {
+				in.In() <- i
+			}
+			in.Close()
+		}()
+		done := make(chan struct{})
+		go func() {
+			chann.FanoutWith(policy, chann.Randomizer(rand.Intn), in, outs...)
+			close(done)
+		}()
+
+		// Ensure that the order is kept for each output, and all
+		// outputs are closed eventually.
+		wg := sync.WaitGroup{}
+		wg.Add(len(outs))
+		count := make([]int, len(outs))
+		for i := range outs {
+			go func(i int) {
+				defer wg.Done()
+				last := -1
+				for v := range outs[i].Out() {
+					if v <= last {
+						t.Errorf("policy %v: out of order, got %v after %v", policy, v, last)
+					}
+					last = v
+					count[i]++
+				}
+			}(i)
+		}
+		wg.Wait()
+		<-done
+		if n := count[0] + count[1] + count[2]; n != 300 {
+			t.Fatalf("policy %v: unexpected count, got %v want %v", policy, n, 300)
+		}
+	}
+}
+
+func TestFanoutSlowOutput(t *testing.T) {
+	in := chann.New[int](chann.Cap(0))
+	slow := chann.New[int](chann.Cap(0))
+	fast := chann.New[int](chann.Cap(0))
+	go func() {
+		for i := 0; i < 10; i++ {
+			in.In() <- i
+		}
+		in.Close()
+	}()
+
+	// Every other value goes to the slow output that is not received
+	// until the end, which must not block the fast output.
+	n := 0
+	go chann.Fanout(func(int) int { n++; return n % 2 }, in, slow, fast)
+	got := []int{}
+	timeout := time.After(time.Second)
+	for len(got) < 5 {
+		select {
+		case v := <-fast.Out():
+			got = append(got, v)
+		case <-timeout:
+			t.Fatalf("fast output is blocked by slow output, got %v", got)
+		}
+	}
+	if _, ok := <-fast.Out(); ok {
+		t.Fatalf("fast output is not closed")
+	}
+	for range slow.Out() {
+	}
+}
+
+func TestLBWith(t *testing.T) {
+	ins := make([]*chann.Chann[int], 10)
+	for i := 0; i < 10; i++ {
+		ins[i] = sendAll(numbers...)
+	}
+	outs := make([]*chann.Chann[int], 10)
+	for i := 0; i < 10; i++ {
+		outs[i] = chann.New[int](chann.Cap(20))
+	}
+	chann.LBWith(chann.Block, chann.LeastLoaded(), ins, outs)
+
+	// The outputs are not received, hence the load seen by the
+	// balancer is accurate except for a value in flight.
+	total := 0
+	for i, out := range outs {
+		n := 0
+		for range out.Out() {
+			n++
+		}
+		if n < 8 || n > 12 {
+			t.Fatalf("output %v is unbalanced, got %v want %v", i, n, 10)
+		}
+		total += n
+	}
+	if total != 100 {
+		t.Fatalf("unexpected total, got %v want %v", total, 100)
+	}
+}
+
+func TestFanoutByKey(t *testing.T) {
+	type msg struct{ key, seq int }
+
+	in := chann.New[msg](chann.Cap(0))
+	outs := make([]*chann.Chann[msg], 4)
+	for i := range outs {
+		outs[i] = chann.New[msg](chann.Cap(0))
+	}
+	go func() {
+		for i := 0; i < 1000; i++ {
+			in.In() <- msg{key: i % 20, seq: i}
+		}
+		in.Close()
+	}()
+	go chann.FanoutByKey(in, func(m msg) int { return m.key }, outs...)
+
+	// Ensure that each key is always sent to the same output, and
+	// the values of a key keep their order.
+	mu := sync.Mutex{}
+	owner := map[int]int{}
+	wg := sync.WaitGroup{}
+	wg.Add(len(outs))
+	for i := range outs {
+		go func(i int) {
+			defer wg.Done()
+			last := map[int]int{}
+			for m := range outs[i].Out() {
+				mu.Lock()
+				if o, ok := owner[m.key]; ok && o != i {
+					t.Errorf("key %v is sent to output %v and %v", m.key, o, i)
+				}
+				owner[m.key] = i
+				mu.Unlock()
+				if s, ok := last[m.key]; ok && m.seq <= s {
+					t.Errorf("key %v is out of order, got %v after %v", m.key, m.seq, s)
+				}
+				last[m.key] = m.seq
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(owner) != 20 {
+		t.Fatalf("unexpected number of keys, got %v want %v", len(owner), 20)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	in := sendAll(numbers...)
+	a, b := chann.Tee(in)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	for _, out := range []*chann.Chann[int]{a, b} {
+		go func(out *chann.Chann[int]) {
+			defer wg.Done()
+			i := 0
+			for v := range out.Out() {
+				if v != i {
+					t.Errorf("unexpected value, got %v want %v", v, i)
+				}
+				i++
+			}
+			if i != 10 {
+				t.Errorf("unexpected count, got %v want %v", i, 10)
+			}
+		}(out)
+	}
+	wg.Wait()
+}
+
+func TestBroadcastStalled(t *testing.T) {
+	for _, policy := range []chann.OverflowPolicy{chann.Drop, chann.Disconnect} {
+		in := chann.New[int](chann.Cap(0))
+		stalled := chann.New[int](chann.Cap(1))
+		live := chann.New[int](chann.Cap(0))
+		go chann.BroadcastWith([]chann.OverflowPolicy{policy, chann.Block}, in, stalled, live)
+
+		// The stalled output is never received, which must not hold
+		// up the live output.
+		for i := 0; i < 10; i++ {
+			in.In() <- i
+			if v := <-live.Out(); v != i {
+				t.Fatalf("policy %v: unexpected value, got %v want %v", policy, v, i)
+			}
+		}
+		in.Close()
+		if _, ok := <-live.Out(); ok {
+			t.Fatalf("policy %v: live output is not closed", policy)
+		}
+
+		// The stalled output only keeps the value that fits in its
+		// buffer, and is closed in both cases.
+		got := []int{}
+		for v := range stalled.Out() {
+			got = append(got, v)
+		}
+		if len(got) != 1 || got[0] != 0 {
+			t.Fatalf("policy %v: unexpected values, got %v want %v", policy, got, []int{0})
+		}
+	}
+}
+
+func TestFaninFair(t *testing.T) {
+	busy := chann.New[int](chann.Cap(100))
+	quiet := chann.New[int](chann.Cap(10))
+	for i := 0; i < 100; i++ {
+		busy.In() <- 0
+	}
+	for i := 0; i < 10; i++ {
+		quiet.In() <- 1
+	}
+	busy.Close()
+	quiet.Close()
+
+	// Both inputs are ready, hence they must be received in turn,
+	// and the busy input must not starve the quiet input.
+	out := chann.FaninFair(busy, quiet)
+	got := []int{}
+	for v := range out.Out() {
+		got = append(got, v)
+	}
+	if len(got) != 110 {
+		t.Fatalf("unexpected count, got %v want %v", len(got), 110)
+	}
+	for i := 0; i < 20; i++ {
+		if got[i] != i%2 {
+			t.Fatalf("inputs are not received in turn, got %v", got[:20])
+		}
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	sorted := func(vs ...int) *chann.Chann[int] {
+		ch := chann.New[int](chann.Cap(0))
+		go func() {
+			for _, v := range vs {
+				ch.In() <- v
+			}
+			ch.Close()
+		}()
+		return ch
+	}
+
+	out := chann.MergeSorted(func(a, b int) bool { return a < b },
+		sorted(1, 4, 7, 10), sorted(), sorted(2, 2, 8), sorted(0, 3, 5, 6, 9))
+	want := []int{0, 1, 2, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	got := []int{}
+	for v := range out.Out() {
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values, got %v want %v", got, want)
+	}
+}
+
+func TestFaninContext(t *testing.T) {
+	out, h := chann.FaninContext(context.Background(), sendAll(numbers...), sendAll(numbers...))
+	count := 0
+	for range out.Out() {
+		count++
+	}
+	if err := h.Wait(); err != nil || count != 20 {
+		t.Fatalf("unexpected result, got %v/%v want %v/%v", count, err, 20, nil)
+	}
+
+	// The inputs are never closed, and the output is never received.
+	grs := runtime.NumGoroutine()
+	ins := []*chann.Chann[int]{chann.New[int](chann.Cap(0)), chann.New[int](chann.Cap(0))}
+	ctx, cancel := context.WithCancel(context.Background())
+	out, h = chann.FaninContext(ctx, ins...)
+	cancel()
+	if err := h.Wait(); err != context.Canceled {
+		t.Fatalf("unexpected error, got %v want %v", err, context.Canceled)
+	}
+	for range out.Out() {
+	}
+	checkGoroutines(t, grs)
+}
+
+func TestFanoutContext(t *testing.T) {
+	outs := []*chann.Chann[int]{chann.New[int](chann.Cap(10)), chann.New[int](chann.Cap(10))}
+	h := chann.FanoutContext(context.Background(), chann.Spill, chann.RoundRobin(), sendAll(numbers...), outs...)
+	if err := h.Wait(); err != nil {
+		t.Fatalf("unexpected error, got %v want %v", err, nil)
+	}
+	for _, out := range outs {
+		if n := len(chann.Collect(out)); n != 5 {
+			t.Fatalf("unexpected count, got %v want %v", n, 5)
+		}
+	}
+
+	// The outputs are never received, hence the values are held by
+	// the forwarding goroutines until the Handle is stopped.
+	grs := runtime.NumGoroutine()
+	in := chann.New[int](chann.Cap(0))
+	outs = []*chann.Chann[int]{chann.New[int](chann.Cap(0)), chann.New[int](chann.Cap(0))}
+	for _, policy := range []chann.OverflowPolicy{chann.Spill, chann.Block} {
+		h = chann.FanoutContext(context.Background(), policy, chann.RoundRobin(), in, outs...)
+		for i := 0; i < 3; i++ {
+			in.In() <- i
+		}
+		h.Stop()
+		if err := h.Wait(); err != context.Canceled {
+			t.Fatalf("unexpected error, got %v want %v", err, context.Canceled)
+		}
+		for _, out := range outs {
+			if _, ok := <-out.Out(); ok {
+				t.Fatalf("output is not closed after stop")
+			}
+		}
+		outs = []*chann.Chann[int]{chann.New[int](chann.Cap(0)), chann.New[int](chann.Cap(0))}
+	}
+	checkGoroutines(t, grs)
+}
+
+func TestLBContext(t *testing.T) {
+	ins := make([]*chann.Chann[int], 10)
+	for i := 0; i < 10; i++ {
+		ins[i] = sendAll(numbers...)
+	}
+	outs := make([]*chann.Chann[int], 10)
+	for i := 0; i < 10; i++ {
+		outs[i] = chann.New[int](chann.Cap(100))
+	}
+	h := chann.LBContext(context.Background(), chann.Block, chann.LeastLoaded(), ins, outs)
+	if err := h.Wait(); err != nil {
+		t.Fatalf("unexpected error, got %v want %v", err, nil)
+	}
+
+	grs := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ins = []*chann.Chann[int]{chann.New[int](chann.Cap(0))}
+	outs = []*chann.Chann[int]{chann.New[int](chann.Cap(0))}
+	h = chann.LBContext(ctx, chann.Block, chann.RoundRobin(), ins, outs)
+	ins[0].In() <- 1
+	if err := h.Wait(); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error, got %v want %v", err, context.DeadlineExceeded)
+	}
+	if _, ok := <-outs[0].Out(); ok {
+		t.Fatalf("output is not closed after stop")
+	}
+	checkGoroutines(t, grs)
+}
+
+// checkGoroutines checks that the number of goroutines drops back to
+// the given number eventually.
+func checkGoroutines(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("leaking goroutines, got %v want %v", runtime.NumGoroutine(), n)
+}
+
+func TestPartition(t *testing.T) {
+	even, odd := chann.Partition(sendAll(numbers...), func(v int) bool { return v%2 == 0 })
+
+	var got []int
+	done := make(chan struct{})
+	go func() {
+		got = chann.Collect(odd)
+		close(done)
+	}()
+	if v := chann.Collect(even); !reflect.DeepEqual(v, []int{0, 2, 4, 6, 8}) {
+		t.Fatalf("unexpected match, got %v", v)
+	}
+	<-done
+	if !reflect.DeepEqual(got, []int{1, 3, 5, 7, 9}) {
+		t.Fatalf("unexpected rest, got %v", got)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	small := chann.New[int](chann.Cap(10))
+	large := chann.New[int](chann.Cap(10))
+	other := chann.New[int](chann.Cap(10))
+	chann.Route(sendAll(numbers...), func(v int) int { return v / 3 }, map[int]*chann.Chann[int]{
+		0: small,
+		1: small,
+		2: large,
+	}, other)
+
+	for _, tt := range []struct {
+		ch   *chann.Chann[int]
+		want []int
+	}{
+		{small, []int{0, 1, 2, 3, 4, 5}},
+		{large, []int{6, 7, 8}},
+		{other, []int{9}},
+	} {
+		if got := chann.Collect(tt.ch); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("unexpected values, got %v want %v", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LBGroup is a load balancer like LB, but its input and output channels
+// can be added and removed at runtime. An LBGroup is safe for concurrent
+// use.
+//
+// Each input is received by a dedicated goroutine, and each output is
+// fed by a dedicated goroutine as FanoutWith does. A value that arrives
+// while the group has no outputs waits until an output is added.
+type LBGroup[T any] struct {
+	policy OverflowPolicy
+	pick   func(v T, loads []Load) int // called with g.mu held for reading
+	ring   *hashRing[*Chann[T]]        // outputs by key, if keyed
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex
+	ins     map[*Chann[T]]*lbInput
+	outs    []*Chann[T]
+	fwds    []*forwarder[T]
+	loads   []Load
+	changed chan struct{}
+	closed  bool
+}
+
+// lbInput is the state of an input of an LBGroup.
+type lbInput struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLBGroup returns an empty LBGroup. The outputs are picked by the
+// Balancer, which is called concurrently by all inputs, and a slow
+// output is handled according to the policy.
+func NewLBGroup[T any](policy OverflowPolicy, b Balancer) *LBGroup[T] {
+	g := newLBGroup[T](policy)
+	g.pick = func(_ T, loads []Load) int { return b.Pick(loads) }
+	return g
+}
+
+// NewKeyedLBGroup returns an empty LBGroup that sends all values with
+// the same key to the same output, as FanoutByKey does, and a slow
+// output is handled according to the policy.
+//
+// The outputs are chosen by consistent hashing, hence adding an output
+// only remaps the keys that it takes over, and removing an output only
+// remaps the keys that belonged to it. The values of a remapped key
+// may be reordered while the output is removed, as the values that it
+// did not receive are redirected concurrently with the new values.
+//
+// The outputs are placed on the ring by their addresses in memory,
+// hence the placement of the keys differs between processes.
+func NewKeyedLBGroup[T any, K comparable](policy OverflowPolicy, key func(T) K) *LBGroup[T] {
+	g := newLBGroup[T](policy)
+	g.ring = newHashRing(defaultReplicas, func(out *Chann[T]) string {
+		return fmt.Sprintf("%p", out)
+	})
+	g.pick = func(v T, _ []Load) int {
+		out := g.ring.get(hashKey(key(v)))
+		for i, o := range g.outs {
+			if o == out {
+				return i
+			}
+		}
+		return -1
+	}
+	return g
+}
+
+func newLBGroup[T any](policy OverflowPolicy) *LBGroup[T] {
+	return &LBGroup[T]{
+		policy:  policy,
+		ins:     make(map[*Chann[T]]*lbInput),
+		changed: make(chan struct{}),
+	}
+}
+
+// AddInput adds the given channel as an input of the group. The input
+// is removed from the group once it is closed. Adding an input that is
+// already in the group has no effect. It panics if the group is closed.
+func (g *LBGroup[T]) AddInput(in *Chann[T]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		panic("chann: add input to closed LBGroup")
+	}
+	if _, ok := g.ins[in]; ok {
+		return
+	}
+	inp := &lbInput{stop: make(chan struct{}), done: make(chan struct{})}
+	g.ins[in] = inp
+	g.wg.Add(1)
+	go g.receive(in, inp)
+}
+
+// RemoveInput stops receiving from the given input channel, and
+// reports whether it was an input of the group. The input is not
+// closed. RemoveInput returns after the value that is being received
+// from the input, if any, is sent to an output.
+func (g *LBGroup[T]) RemoveInput(in *Chann[T]) bool {
+	g.mu.Lock()
+	inp, ok := g.ins[in]
+	delete(g.ins, in)
+	g.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	close(inp.stop)
+	<-inp.done
+	return true
+}
+
+// AddOutput adds the given channel as an output of the group. Adding
+// an output that is already in the group has no effect. It panics if
+// the group is closed.
+func (g *LBGroup[T]) AddOutput(out *Chann[T]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		panic("chann: add output to closed LBGroup")
+	}
+	for _, o := range g.outs {
+		if o == out {
+			return
+		}
+	}
+	f := newForwarder(g.policy, out)
+	g.outs = append(g.outs, out)
+	g.fwds = append(g.fwds, f)
+	g.loads = append(g.loads, f)
+	if g.ring != nil {
+		g.ring.add(out)
+	}
+	g.notify()
+}
+
+// RemoveOutput removes the given output channel from the group and
+// closes it, and reports whether it was an output of the group. The
+// values that were picked for the output but not yet delivered to it
+// are redirected to the remaining outputs, unless the group is being
+// closed, in which case they are discarded.
+func (g *LBGroup[T]) RemoveOutput(out *Chann[T]) bool {
+	g.mu.Lock()
+	i := -1
+	for j, o := range g.outs {
+		if o == out {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		g.mu.Unlock()
+		return false
+	}
+	f := g.fwds[i]
+	g.outs = append(g.outs[:i:i], g.outs[i+1:]...)
+	g.fwds = append(g.fwds[:i:i], g.fwds[i+1:]...)
+	g.loads = append(g.loads[:i:i], g.loads[i+1:]...)
+	if g.ring != nil {
+		g.ring.remove(out)
+	}
+	// The remaining outputs may be closed by Close once the inputs are
+	// finished, hence the values are only redirected before Close.
+	redirect := !g.closed
+	if redirect {
+		g.wg.Add(1)
+	}
+	g.mu.Unlock()
+
+	rest := f.detach()
+	if redirect {
+		go func() {
+			defer g.wg.Done()
+			for _, v := range rest {
+				if !g.dispatch(v) {
+					return
+				}
+			}
+		}()
+	}
+	return true
+}
+
+// Close stops receiving from all inputs, and closes all outputs once
+// the values that were received are delivered. Close returns after all
+// goroutines of the group are finished. If the group has no outputs,
+// the values that are waiting for an output are discarded. With the
+// Block policy, Close waits for a stalled output to receive, unless
+// the output is removed meanwhile by RemoveOutput, which discards the
+// values that the output did not receive. It is safe to call Close
+// more than once.
+func (g *LBGroup[T]) Close() {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		g.wg.Wait()
+		return
+	}
+	g.closed = true
+	g.notify()
+	ins := g.ins
+	g.ins = make(map[*Chann[T]]*lbInput)
+	g.mu.Unlock()
+
+	for _, inp := range ins {
+		close(inp.stop)
+	}
+	// Wait for the inputs and redirections before closing the outputs,
+	// so that no more values are sent to the forwarders.
+	g.wg.Wait()
+
+	// The outputs stay in the group until their forwarders are done,
+	// so that a stalled output can still be removed.
+	g.mu.RLock()
+	fwds := append([]*forwarder[T](nil), g.fwds...)
+	g.mu.RUnlock()
+
+	for _, f := range fwds {
+		f.close()
+	}
+	for _, f := range fwds {
+		<-f.done
+	}
+
+	g.mu.Lock()
+	g.outs, g.fwds, g.loads = nil, nil, nil
+	g.mu.Unlock()
+}
+
+// receive is the processing loop of an input.
+func (g *LBGroup[T]) receive(in *Chann[T], inp *lbInput) {
+	defer g.wg.Done()
+	defer close(inp.done)
+
+	for {
+		select {
+		case v, ok := <-in.Out():
+			if !ok {
+				g.mu.Lock()
+				if g.ins[in] == inp {
+					delete(g.ins, in)
+				}
+				g.mu.Unlock()
+				return
+			}
+			if !g.dispatch(v) {
+				return
+			}
+		case <-inp.stop:
+			return
+		}
+	}
+}
+
+// dispatch sends the given value to an output picked by the group,
+// and waits if there is no output. It reports whether the value was
+// sent, which is false only if the group is closed without outputs.
+func (g *LBGroup[T]) dispatch(v T) bool {
+	for {
+		g.mu.RLock()
+		if len(g.fwds) > 0 {
+			i := g.pick(v, g.loads)
+			if i < 0 || i >= len(g.fwds) {
+				g.mu.RUnlock()
+				panic("chann: balancer picked an out-of-range output")
+			}
+			f := g.fwds[i]
+			g.mu.RUnlock()
+			// Send without holding the lock, so that a stalled output
+			// can be removed meanwhile, in which case the value is
+			// dispatched again. The forwarders are only closed after
+			// all dispatches are finished.
+			if f.trySend(v) {
+				return true
+			}
+			continue
+		}
+		if g.closed {
+			g.mu.RUnlock()
+			return false
+		}
+		changed := g.changed
+		g.mu.RUnlock()
+		<-changed
+	}
+}
+
+// notify wakes up all values that are waiting for an output. The caller
+// must hold g.mu.
+func (g *LBGroup[T]) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
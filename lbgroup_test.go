@@ -0,0 +1,213 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestLBGroup(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy chann.OverflowPolicy
+	}{
+		{"spill", chann.Spill},
+		{"block", chann.Block},
+	} {
+		t.Run(tt.name, func(t *testing.T) { testLBGroup(t, tt.policy) })
+	}
+}
+
+func testLBGroup(t *testing.T, policy chann.OverflowPolicy) {
+	g := chann.NewLBGroup[int](policy, chann.RoundRobin())
+
+	var (
+		mu  sync.Mutex
+		got = map[int]int{}
+		wg  sync.WaitGroup
+	)
+	worker := func() *chann.Chann[int] {
+		out := chann.New[int](chann.Cap(0))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out.Out() {
+				mu.Lock()
+				got[v]++
+				mu.Unlock()
+			}
+		}()
+		return out
+	}
+	send := func(in *chann.Chann[int], from, to int, last bool) <-chan struct{} {
+		sent := make(chan struct{})
+		go func() {
+			for i := from; i < to; i++ {
+				in.In() <- i
+			}
+			if last {
+				in.Close()
+			}
+			close(sent)
+		}()
+		return sent
+	}
+
+	// Values that arrive before any output is added must wait.
+	in1 := chann.New[int](chann.Cap(0))
+	g.AddInput(in1)
+	sent1 := send(in1, 0, 100, true)
+
+	// A removed output that never receives must not lose the values
+	// picked for it, and must not block the group, even with the Block
+	// policy where the inputs wait for it.
+	stalled := chann.New[int](chann.Cap(0))
+	g.AddOutput(stalled)
+	w1 := worker()
+	g.AddOutput(w1)
+	g.AddOutput(worker())
+
+	in2 := chann.New[int](chann.Cap(0))
+	g.AddInput(in2)
+	sent2 := send(in2, 100, 200, false)
+	time.Sleep(10 * time.Millisecond)
+
+	// The values picked for the stalled output are redirected once
+	// it is removed, and the output is closed.
+	if !g.RemoveOutput(stalled) || g.RemoveOutput(stalled) {
+		t.Fatalf("unexpected remove output result")
+	}
+	if _, ok := <-stalled.Out(); ok {
+		t.Fatalf("removed output is not closed")
+	}
+
+	<-sent2
+	if !g.RemoveInput(in2) || g.RemoveInput(in2) {
+		t.Fatalf("unexpected remove input result")
+	}
+	g.RemoveOutput(w1)
+	<-sent1
+	g.Close()
+	g.Close()
+	wg.Wait()
+
+	if len(got) != 200 {
+		t.Fatalf("unexpected number of values, got %v want %v", len(got), 200)
+	}
+	for v, n := range got {
+		if n != 1 {
+			t.Fatalf("value %v is received %v times", v, n)
+		}
+	}
+}
+
+func TestLBGroupCloseRemove(t *testing.T) {
+	g := chann.NewLBGroup[int](chann.Block, chann.RoundRobin())
+	stalled := chann.New[int](chann.Cap(0))
+	g.AddOutput(stalled)
+	in := chann.New[int](chann.Cap(0))
+	g.AddInput(in)
+	in.In() <- 1
+
+	// Close waits for the stalled output, until it is removed.
+	closed := make(chan struct{})
+	go func() {
+		g.Close()
+		close(closed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if !g.RemoveOutput(stalled) {
+		t.Fatalf("stalled output is not removed while closing")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("close is blocked after removing the stalled output")
+	}
+	if _, ok := <-stalled.Out(); ok {
+		t.Fatalf("removed output is not closed")
+	}
+}
+
+func TestKeyedLBGroup(t *testing.T) {
+	const keys = 1000
+	g := chann.NewKeyedLBGroup(chann.Spill, func(v int) int { return v })
+	in := chann.New[int](chann.Cap(0))
+	g.AddInput(in)
+
+	var (
+		mu    sync.Mutex
+		owner = map[int]*chann.Chann[int]{}
+		recv  sync.WaitGroup
+		wg    sync.WaitGroup
+	)
+	worker := func() *chann.Chann[int] {
+		out := chann.New[int](chann.Cap(0))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out.Out() {
+				mu.Lock()
+				owner[v] = out
+				mu.Unlock()
+				recv.Done()
+			}
+		}()
+		g.AddOutput(out)
+		return out
+	}
+	// round sends every key once, and returns the output of each key.
+	round := func() map[int]*chann.Chann[int] {
+		recv.Add(keys)
+		for v := 0; v < keys; v++ {
+			in.In() <- v
+		}
+		recv.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		m := make(map[int]*chann.Chann[int], len(owner))
+		for k, out := range owner {
+			m[k] = out
+		}
+		return m
+	}
+
+	for i := 0; i < 3; i++ {
+		worker()
+	}
+	before := round()
+
+	// The added output only takes over some keys from the others.
+	added := worker()
+	after := round()
+	moved := 0
+	for k, out := range after {
+		if out != before[k] {
+			if out != added {
+				t.Fatalf("key %v is moved between existing outputs", k)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > keys/2 {
+		t.Fatalf("unexpected number of moved keys: %v", moved)
+	}
+
+	// Removing the output gives its keys back.
+	g.RemoveOutput(added)
+	if got := round(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("keys are not given back after removing the output")
+	}
+	in.Close()
+	g.Close()
+	wg.Wait()
+}
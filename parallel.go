@@ -0,0 +1,265 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ParallelOpt represents an option to configure ParallelMap and
+// ParallelMapErr, which is either Unordered, Window, CollectErrors, or
+// an Opt that configures the results channel, such as Cap.
+type ParallelOpt interface {
+	applyParallel(*parallelConfig)
+}
+
+type parallelConfig struct {
+	unordered bool
+	window    int
+	opts      []Opt
+	collect   bool
+}
+
+type parallelOpt func(*parallelConfig)
+
+func (o parallelOpt) applyParallel(c *parallelConfig) { o(c) }
+func (o Opt) applyParallel(c *parallelConfig)         { c.opts = append(c.opts, o) }
+
+// Unordered configures the results to be sent as soon as they are
+// ready, rather than in the order of their inputs, which gives the
+// maximum throughput.
+func Unordered() ParallelOpt {
+	return parallelOpt(func(c *parallelConfig) { c.unordered = true })
+}
+
+// Window configures the maximum number of values that are received
+// from the input but whose results are not yet sent, which includes
+// the values being processed and the results waiting for an earlier
+// result in the reorder buffer. Once the window is full, no more
+// values are received from the input until a result is sent. By
+// default, the window is twice the number of workers.
+func Window(n int) ParallelOpt {
+	return parallelOpt(func(c *parallelConfig) { c.window = n })
+}
+
+// CollectErrors configures ParallelMapErr to skip the values whose
+// function returns an error, and to report all errors when it ends.
+// By default, ParallelMapErr fails fast: it stops at the first error
+// and reports only that error.
+func CollectErrors() ParallelOpt {
+	return parallelOpt(func(c *parallelConfig) { c.collect = true })
+}
+
+// ParallelMap returns a channel that receives fn(v) for every value v
+// received from in, where fn is called by the given number of workers
+// in parallel. The results are sent in the order of their inputs,
+// unless Unordered is specified. The returned channel is unbuffered
+// by default, and is closed once in is closed and all results are
+// sent, or once the context is done.
+func ParallelMap[T, R any](ctx context.Context, in *Chann[T], workers int, fn func(T) R, opts ...ParallelOpt) *Chann[R] {
+	out, _ := ParallelMapErr(ctx, in, workers, func(v T) (R, error) {
+		return fn(v), nil
+	}, opts...)
+	return out
+}
+
+// ParallelMapErr is like ParallelMap, but fn may return an error. The
+// returned Handle reports the errors by Wait, according to whether
+// CollectErrors is specified, or the error of the context if it is
+// done before in is closed.
+func ParallelMapErr[T, R any](ctx context.Context, in *Chann[T], workers int, fn func(T) (R, error), opts ...ParallelOpt) (*Chann[R], *Handle) {
+	if workers < 1 {
+		workers = 1
+	}
+	cfg := parallelConfig{window: 2 * workers}
+	for _, o := range opts {
+		o.applyParallel(&cfg)
+	}
+	if cfg.window < workers {
+		cfg.window = workers
+	}
+
+	type job struct {
+		seq int
+		v   T
+	}
+	type result struct {
+		seq int
+		r   R
+		err error
+	}
+	var (
+		out, _  = newOutput[R](cfg.opts)
+		window  = make(chan struct{}, cfg.window)
+		jobs    = make(chan job)
+		results = make(chan result)
+		wg      sync.WaitGroup
+	)
+	ctx, h := newHandle(ctx)
+
+	// The dispatcher receives values from in as long as the window
+	// is not full.
+	h.goFunc(func() error {
+		for seq := 0; ; seq++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					close(jobs)
+					return nil
+				}
+				select {
+				case jobs <- job{seq: seq, v: v}:
+				case <-ctx.Done():
+					return nil
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		h.goFunc(func() error {
+			defer wg.Done()
+			for {
+				select {
+				case j, ok := <-jobs:
+					if !ok {
+						return nil
+					}
+					r, err := fn(j.v)
+					select {
+					case results <- result{seq: j.seq, r: r, err: err}:
+					case <-ctx.Done():
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+	}
+	h.goFunc(func() error {
+		wg.Wait()
+		close(results)
+		return nil
+	})
+
+	// The collector sends the results, and reorders them if needed.
+	h.goFunc(func() error {
+		var (
+			errs    []error
+			next    int
+			pending = map[int]result{}
+		)
+		send := func(res result) error {
+			<-window
+			if res.err != nil {
+				if !cfg.collect {
+					h.Stop()
+					return res.err
+				}
+				errs = append(errs, res.err)
+				return nil
+			}
+			select {
+			case out.In() <- res.r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		for {
+			select {
+			case res, ok := <-results:
+				if !ok {
+					return joinErrors(errs)
+				}
+				if cfg.unordered {
+					if err := send(res); err != nil {
+						return err
+					}
+					continue
+				}
+				pending[res.seq] = res
+				for {
+					res, ok := pending[next]
+					if !ok {
+						break
+					}
+					delete(pending, next)
+					next++
+					if err := send(res); err != nil {
+						return err
+					}
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	h.start(out.Close)
+	return out, h
+}
+
+// joinErrors returns an error that wraps all the given errors, or nil
+// if there is no error.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return multiError(errs)
+}
+
+// multiError is an error that wraps multiple errors.
+type multiError []error
+
+func (e multiError) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Unwrap returns the wrapped errors, which are inspected by errors.Is
+// and errors.As since Go 1.20.
+func (e multiError) Unwrap() []error { return e }
+
+// Is reports whether any wrapped error matches the target, so that
+// errors.Is inspects the wrapped errors before Go 1.20 as well.
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first wrapped error that matches the target, and sets
+// the target to it, as errors.As does.
+func (e multiError) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func jitter(v int) int {
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+	return v * 2
+}
+
+func TestParallelMap(t *testing.T) {
+	vs, want := make([]int, 100), make([]int, 100)
+	for i := range want {
+		vs[i], want[i] = i, i*2
+	}
+
+	got := chann.Collect(chann.ParallelMap(context.Background(), sendAll(vs...), 8, jitter))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("results are not in order, got %v", got)
+	}
+
+	got = chann.Collect(chann.ParallelMap(context.Background(), sendAll(vs...), 8, jitter, chann.Unordered()))
+	sort.Ints(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected results, got %v", got)
+	}
+}
+
+func TestParallelMapWindow(t *testing.T) {
+	// The first value is slow, and the results after it must wait in
+	// the reorder buffer, which is bounded by the window.
+	var received int64
+	in := chann.New[int](chann.Cap(0))
+	go func() {
+		for i := 0; i < 100; i++ {
+			in.In() <- i
+			atomic.AddInt64(&received, 1)
+		}
+		in.Close()
+	}()
+	release := make(chan struct{})
+	out := chann.ParallelMap(context.Background(), in, 4, func(v int) int {
+		if v == 0 {
+			<-release
+		}
+		return v
+	}, chann.Window(8))
+
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt64(&received); n > 9 {
+		t.Fatalf("window is not respected, received %v values", n)
+	}
+	close(release)
+	if n := len(chann.Collect(out)); n != 100 {
+		t.Fatalf("unexpected count, got %v want %v", n, 100)
+	}
+}
+
+func TestParallelMapErr(t *testing.T) {
+	errOdd := errors.New("odd")
+	fn := func(v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v, nil
+	}
+
+	out, h := chann.ParallelMapErr(context.Background(), sendAll(numbers...), 4, fn, chann.CollectErrors())
+	if got := chann.Collect(out); !reflect.DeepEqual(got, []int{0, 2, 4, 6, 8}) {
+		t.Fatalf("unexpected results, got %v", got)
+	}
+	err := h.Wait()
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("unexpected error, got %v want %v", err, errOdd)
+	}
+	// The errors are matched without relying on Unwrap() []error,
+	// which errors.Is only supports since Go 1.20.
+	if is, ok := err.(interface{ Is(error) bool }); !ok || !is.Is(errOdd) {
+		t.Fatalf("collected errors do not match %v", errOdd)
+	}
+
+	out, h = chann.ParallelMapErr(context.Background(), sendAll(numbers...), 4, fn)
+	if got := chann.Collect(out); len(got) > 1 {
+		t.Fatalf("results are sent after the first error, got %v", got)
+	}
+	if err := h.Wait(); err != errOdd {
+		t.Fatalf("unexpected error, got %v want %v", err, errOdd)
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A Pipeline is a graph of named stages connected by channels. The
+// stages and the channels are declared by Stage and Connect, and the
+// graph is checked and started by Start.
+//
+// A stage is a function that receives from its input channels and sends
+// to its output channels. Once a stage returns, its output channels are
+// closed by the pipeline, hence a stage is expected to return once its
+// input channels are closed, so that closing propagates downstream. A
+// stage without input, namely a source, is expected to return once its
+// context is done.
+//
+// A Pipeline must not be changed once started, and is not safe for
+// concurrent use while it is built.
+type Pipeline struct {
+	stages []*pipeStage
+	index  map[string]*pipeStage
+	edges  []*pipeEdge
+	errs   []error
+	order  []*pipeStage // stages in topological order
+	h      *Handle
+	once   sync.Once
+	err    error
+}
+
+type pipeStage struct {
+	name    string
+	fn      func(context.Context) error
+	in, out []*pipeEdge
+	cancel  context.CancelFunc
+	drained int32 // set once the stage is told to stop by Stop
+	done    chan struct{}
+}
+
+type pipeEdge struct {
+	from, to string
+	typ      string
+	close    func()
+}
+
+// NewPipeline returns an empty Pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{index: map[string]*pipeStage{}}
+}
+
+// Stage adds a stage of the given name that runs fn, and returns the
+// Pipeline to allow chaining. The context passed to fn is done once
+// the pipeline is stopped, or once another stage fails.
+func (p *Pipeline) Stage(name string, fn func(ctx context.Context) error) *Pipeline {
+	if _, ok := p.index[name]; ok {
+		p.errs = append(p.errs, fmt.Errorf("chann: duplicate pipeline stage %q", name))
+		return p
+	}
+	s := &pipeStage{name: name, fn: fn, done: make(chan struct{})}
+	p.stages = append(p.stages, s)
+	p.index[name] = s
+	return p
+}
+
+// Connect returns a channel from the stage named from to the stage
+// named to, which is closed once the stage named from returns. The
+// stages may be added before or after the channel. The options are
+// the same as for New, except that the channel is unbuffered by
+// default.
+func Connect[T any](p *Pipeline, from, to string, opts ...Opt) *Chann[T] {
+	if len(opts) == 0 {
+		opts = []Opt{Cap(0)}
+	}
+	ch := New[T](opts...)
+	p.edges = append(p.edges, &pipeEdge{
+		from:  from,
+		to:    to,
+		typ:   reflect.TypeOf((*T)(nil)).Elem().String(),
+		close: ch.Close,
+	})
+	return ch
+}
+
+// Start checks the graph of the Pipeline, and starts all stages if the
+// graph is valid. It returns an error without starting any stage if a
+// channel connects a stage that does not exist, or if the channels
+// form a cycle. The stages are stopped once the context is done.
+func (p *Pipeline) Start(ctx context.Context) error {
+	if p.h != nil {
+		panic("chann: pipeline already started")
+	}
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	ctx, p.h = newHandle(ctx)
+	for _, s := range p.stages {
+		s := s
+		sctx, cancel := context.WithCancel(ctx)
+		s.cancel = cancel
+		p.h.goFunc(func() error {
+			defer close(s.done)
+			defer cancel()
+			err := s.fn(sctx)
+			for _, e := range s.out {
+				e.close()
+			}
+			if err != nil && !(atomic.LoadInt32(&s.drained) == 1 && errors.Is(err, context.Canceled)) {
+				p.fail(fmt.Errorf("chann: pipeline stage %q: %w", s.name, err))
+			}
+			return nil
+		})
+	}
+	p.h.start(nil)
+	return nil
+}
+
+// fail records the first error of the stages, and stops all stages.
+func (p *Pipeline) fail(err error) {
+	p.once.Do(func() { p.err = err })
+	p.h.Stop()
+}
+
+// Wait waits for all stages to return, and returns the first error
+// returned by a stage, wrapped with the name of the stage, which is the
+// error of the context if the stages are stopped by the context given
+// to Start. Once a stage fails, all stages are stopped. Wait must be
+// called after Start.
+func (p *Pipeline) Wait() error {
+	<-p.h.Done()
+	return p.err
+}
+
+// Stop stops the Pipeline gracefully, and waits for all stages to
+// return. The sources are told to stop first, and then each stage is
+// waited in topological order, so that the values in flight are
+// drained downstream before the pipeline ends. If the context is done
+// before all stages return, all stages are told to stop at once, and
+// Stop returns the error of the context. Otherwise, Stop returns the
+// error reported by Wait. Stop must be called after Start.
+func (p *Pipeline) Stop(ctx context.Context) error {
+	for _, s := range p.order {
+		if len(s.in) == 0 {
+			atomic.StoreInt32(&s.drained, 1)
+			s.cancel()
+		}
+	}
+	for _, s := range p.order {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			p.h.Stop()
+			<-p.h.Done()
+			return ctx.Err()
+		}
+	}
+	return p.Wait()
+}
+
+// DOT returns the graph of the Pipeline in the DOT language, where the
+// edges are labeled by the types of the values of the channels.
+func (p *Pipeline) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	for _, s := range p.stages {
+		fmt.Fprintf(&b, "\t%s;\n", strconv.Quote(s.name))
+	}
+	for _, e := range p.edges {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n",
+			strconv.Quote(e.from), strconv.Quote(e.to), strconv.Quote(e.typ))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// check links the stages by the channels, and sorts the stages in
+// topological order. It reports the first problem of the graph.
+func (p *Pipeline) check() error {
+	if len(p.errs) > 0 {
+		return p.errs[0]
+	}
+	p.order = nil
+	for _, s := range p.stages {
+		s.in, s.out = nil, nil
+	}
+	for _, e := range p.edges {
+		from, ok := p.index[e.from]
+		if !ok {
+			return fmt.Errorf("chann: pipeline channel %q -> %q from unknown stage", e.from, e.to)
+		}
+		to, ok := p.index[e.to]
+		if !ok {
+			return fmt.Errorf("chann: pipeline channel %q -> %q to unknown stage", e.from, e.to)
+		}
+		from.out = append(from.out, e)
+		to.in = append(to.in, e)
+	}
+
+	// Kahn's algorithm, which keeps the order of declaration among the
+	// stages that are ready at the same time.
+	indeg := make(map[*pipeStage]int, len(p.stages))
+	for _, s := range p.stages {
+		indeg[s] = len(s.in)
+	}
+	var queue []*pipeStage
+	for _, s := range p.stages {
+		if indeg[s] == 0 {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		p.order = append(p.order, s)
+		for _, e := range s.out {
+			to := p.index[e.to]
+			if indeg[to]--; indeg[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+	if len(p.order) == len(p.stages) {
+		return nil
+	}
+
+	// Every stage left has an input from another stage left, hence
+	// following the inputs backwards from any of them leads to a cycle.
+	var (
+		s    *pipeStage
+		path []string
+		seen = map[*pipeStage]int{}
+	)
+	for _, s = range p.stages {
+		if indeg[s] > 0 {
+			break
+		}
+	}
+	for {
+		if i, ok := seen[s]; ok {
+			path = append(path[i:], s.name)
+			break
+		}
+		seen[s] = len(path)
+		path = append(path, s.name)
+		for _, e := range s.in {
+			if from := p.index[e.from]; indeg[from] > 0 {
+				s = from
+				break
+			}
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	p.order = nil
+	return fmt.Errorf("chann: pipeline has a cycle: %s", strings.Join(path, " -> "))
+}
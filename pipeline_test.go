@@ -0,0 +1,193 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+// counter is a source stage that sends increasing numbers, up to n if
+// n is positive, or until its context is done otherwise.
+func counter(out *chann.Chann[int], n int) func(context.Context) error {
+	return func(ctx context.Context) error {
+		for i := 0; n <= 0 || i < n; i++ {
+			select {
+			case out.In() <- i:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+// collector is a sink stage that appends the received values to vs.
+func collector(in *chann.Chann[int], vs *[]int) func(context.Context) error {
+	return func(ctx context.Context) error {
+		for v := range in.Out() {
+			*vs = append(*vs, v)
+		}
+		return nil
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := chann.NewPipeline()
+	nums := chann.Connect[int](p, "source", "square")
+	squares := chann.Connect[int](p, "square", "sink")
+
+	var got []int
+	p.Stage("sink", collector(squares, &got)).
+		Stage("source", counter(nums, 4)).
+		Stage("square", func(ctx context.Context) error {
+			for v := range nums.Out() {
+				select {
+				case squares.In() <- v * v:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{0, 1, 4, 9}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values, got %v want %v", got, want)
+	}
+
+	want := `digraph pipeline {
+	"sink";
+	"source";
+	"square";
+	"source" -> "square" [label="int"];
+	"square" -> "sink" [label="int"];
+}
+`
+	if dot := p.DOT(); dot != want {
+		t.Fatalf("unexpected DOT, got:\n%v\nwant:\n%v", dot, want)
+	}
+}
+
+func TestPipelineStop(t *testing.T) {
+	p := chann.NewPipeline()
+	nums := chann.Connect[int](p, "source", "sink", chann.Cap(10))
+
+	var got []int
+	p.Stage("source", counter(nums, 0)).Stage("sink", collector(nums, &got))
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// The values sent by the source are drained by the sink.
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatalf("no value is received")
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("value %v is lost", i)
+		}
+	}
+}
+
+func TestPipelineStopTimeout(t *testing.T) {
+	p := chann.NewPipeline()
+	nums := chann.Connect[int](p, "source", "sink")
+	p.Stage("source", counter(nums, 0)).Stage("sink", func(ctx context.Context) error {
+		// The sink does not receive, and blocks the source.
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error, got %v want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	errFail := errors.New("fail")
+	p := chann.NewPipeline()
+	nums := chann.Connect[int](p, "source", "sink")
+	p.Stage("source", counter(nums, 0)).Stage("sink", func(ctx context.Context) error {
+		<-nums.Out()
+		return errFail
+	})
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The failure stops the source.
+	err := p.Wait()
+	if !errors.Is(err, errFail) || !strings.Contains(err.Error(), `"sink"`) {
+		t.Fatalf("unexpected error, got %v want %v", err, errFail)
+	}
+}
+
+func TestPipelineCheck(t *testing.T) {
+	nop := func(context.Context) error { return nil }
+	tests := []struct {
+		build func(p *chann.Pipeline)
+		err   string
+	}{
+		{
+			func(p *chann.Pipeline) {
+				p.Stage("a", nop).Stage("a", nop)
+			},
+			`duplicate pipeline stage "a"`,
+		},
+		{
+			func(p *chann.Pipeline) {
+				p.Stage("a", nop)
+				chann.Connect[int](p, "a", "b")
+			},
+			`pipeline channel "a" -> "b" to unknown stage`,
+		},
+		{
+			func(p *chann.Pipeline) {
+				p.Stage("a", nop).Stage("b", nop).Stage("c", nop).Stage("d", nop)
+				chann.Connect[int](p, "a", "b")
+				chann.Connect[int](p, "b", "c")
+				chann.Connect[int](p, "c", "d")
+				chann.Connect[int](p, "d", "b")
+			},
+			`pipeline has a cycle: b -> c -> d -> b`,
+		},
+		{
+			func(p *chann.Pipeline) {
+				p.Stage("a", nop)
+				chann.Connect[string](p, "a", "a")
+			},
+			`pipeline has a cycle: a -> a`,
+		},
+	}
+	for _, tt := range tests {
+		p := chann.NewPipeline()
+		tt.build(p)
+		if err := p.Start(context.Background()); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("unexpected error, got %v want %v", err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolOpt represents an option to configure a Pool, which is either
+// PoolWorkers, PoolAutoscale, or an Opt that configures the results
+// channel, such as Cap.
+type PoolOpt interface {
+	applyPool(*poolConfig)
+}
+
+type poolConfig struct {
+	min, max int
+	idle     time.Duration
+	opts     []Opt
+}
+
+type poolOpt func(*poolConfig)
+
+func (o poolOpt) applyPool(c *poolConfig) { o(c) }
+func (o Opt) applyPool(c *poolConfig)     { c.opts = append(c.opts, o) }
+
+// PoolWorkers configures a Pool to run a fixed number of n workers.
+// By default, a Pool runs runtime.GOMAXPROCS(0) workers.
+func PoolWorkers(n int) PoolOpt {
+	return poolOpt(func(c *poolConfig) {
+		if n < 1 {
+			n = 1
+		}
+		c.min, c.max, c.idle = n, n, 0
+	})
+}
+
+// PoolAutoscale configures a Pool to run between min and max workers.
+// A worker is added when a value is submitted while there are at least
+// as many waiting values as idle workers, and a worker exits after being
+// idle for the given duration, as long as there are more than min
+// workers.
+func PoolAutoscale(min, max int, idle time.Duration) PoolOpt {
+	return poolOpt(func(c *poolConfig) {
+		if min < 1 {
+			min = 1
+		}
+		if max < min {
+			max = min
+		}
+		c.min, c.max, c.idle = min, max, idle
+	})
+}
+
+// Pool is a work-stealing worker pool. Each worker has a local unbounded
+// queue of values, and steals values from the queues of its peers when
+// its own queue is empty. The results of the workers are sent to the
+// channel returned by Results, whose order is unspecified. A Pool is
+// safe for concurrent use.
+type Pool[T, R any] struct {
+	pending int64 // accessed atomically, keep it 64-bit aligned
+	idle    int64 // accessed atomically
+	fn      func(T) R
+	cfg     poolConfig
+	results *Chann[R]
+	notify  chan struct{}
+	quit    chan struct{}
+	drained chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	workers []*poolWorker[T]
+	retired WorkerStats // totals of the retired workers
+	next    int
+	nextID  int
+	closed  bool
+}
+
+// poolWorker is a worker of a Pool.
+type poolWorker[T any] struct {
+	processed uint64 // accessed atomically
+	stolen    uint64 // accessed atomically
+	queued    int64  // accessed atomically
+	id        int
+	queue     *Chann[T]
+}
+
+// WorkerStats describes the statistics of a worker of a Pool.
+type WorkerStats struct {
+	// ID is a unique number of the worker within the Pool.
+	ID int
+	// Processed is the number of values processed by the worker.
+	Processed uint64
+	// Stolen is the number of values that the worker stole from
+	// its peers, which are included in Processed.
+	Stolen uint64
+	// Queued is the number of values in the local queue of the
+	// worker, which are waiting to be processed.
+	Queued int
+}
+
+// NewPool returns a new Pool that applies fn to every submitted value.
+// By default, the results channel is unbuffered.
+func NewPool[T, R any](fn func(T) R, opts ...PoolOpt) *Pool[T, R] {
+	n := runtime.GOMAXPROCS(0)
+	cfg := poolConfig{min: n, max: n}
+	for _, o := range opts {
+		o.applyPool(&cfg)
+	}
+	results, _ := newOutput[R](cfg.opts)
+	p := &Pool[T, R]{
+		fn:      fn,
+		cfg:     cfg,
+		results: results,
+		notify:  make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+	p.mu.Lock()
+	for i := 0; i < cfg.min; i++ {
+		p.spawn()
+	}
+	p.mu.Unlock()
+	return p
+}
+
+// Results returns the channel that receives the results of the Pool.
+// The channel is closed when the Pool is shut down.
+func (p *Pool[T, R]) Results() *Chann[R] { return p.results }
+
+// Submit submits the given value to be processed by a worker, and
+// never blocks. It reports whether the value was submitted, which is
+// false only if the Pool is shut down.
+func (p *Pool[T, R]) Submit(v T) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	if len(p.workers) < p.cfg.max {
+		// Add a worker if every idle worker already has a value
+		// waiting for it.
+		idle := atomic.LoadInt64(&p.idle)
+		waiting := atomic.LoadInt64(&p.pending) - (int64(len(p.workers)) - idle)
+		if waiting >= idle {
+			p.spawn()
+		}
+	}
+	w := p.workers[p.next%len(p.workers)]
+	p.next++
+	atomic.AddInt64(&p.pending, 1)
+	atomic.AddInt64(&w.queued, 1)
+	w.queue.In() <- v
+
+	// Wake up an idle worker, which may steal the value if the worker
+	// that owns it is busy.
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Stats returns the statistics of the current workers. Once the Pool
+// is shut down, it returns the final statistics of the workers that
+// were running at that time.
+func (p *Pool[T, R]) Stats() []WorkerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]WorkerStats, len(p.workers))
+	for i, w := range p.workers {
+		stats[i] = w.stats()
+	}
+	return stats
+}
+
+// Totals returns the statistics of all workers that ever ran in the
+// Pool summed up, including the workers that exited after being idle.
+// The ID of the returned statistics is zero.
+func (p *Pool[T, R]) Totals() WorkerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total := p.retired
+	for _, w := range p.workers {
+		s := w.stats()
+		total.Processed += s.Processed
+		total.Stolen += s.Stolen
+		total.Queued += s.Queued
+	}
+	return total
+}
+
+// stats returns the statistics of the worker.
+func (w *poolWorker[T]) stats() WorkerStats {
+	return WorkerStats{
+		ID:        w.id,
+		Processed: atomic.LoadUint64(&w.processed),
+		Stolen:    atomic.LoadUint64(&w.stolen),
+		Queued:    int(atomic.LoadInt64(&w.queued)),
+	}
+}
+
+// Shutdown stops accepting values, waits for all submitted values to
+// be processed and their results to be received, and then stops all
+// workers and closes the results channel. If the context is done
+// first, the remaining values are discarded, and Shutdown returns the
+// error of the context after stopping the workers. It is safe to call
+// Shutdown more than once.
+func (p *Pool[T, R]) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	first := !p.closed
+	p.closed = true
+	p.mu.Unlock()
+
+	if first && atomic.LoadInt64(&p.pending) == 0 {
+		p.drain()
+	}
+	var err error
+	select {
+	case <-p.drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	select {
+	case <-p.quit:
+		p.mu.Unlock()
+		p.wg.Wait()
+		return err
+	default:
+	}
+	close(p.quit)
+	workers := p.workers
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, w := range workers {
+		w.queue.Close()
+	}
+	p.results.Close()
+	return err
+}
+
+// spawn starts a new worker. The caller must hold p.mu.
+func (p *Pool[T, R]) spawn() {
+	w := &poolWorker[T]{id: p.nextID, queue: New[T]()}
+	p.nextID++
+	p.workers = append(p.workers, w)
+	atomic.AddInt64(&p.idle, 1)
+	p.wg.Add(1)
+	go p.work(w)
+}
+
+// retire removes the given idle worker if the Pool has more than the
+// minimal number of workers and the local queue of the worker is empty.
+// The statistics of the worker are added to the totals of the retired
+// workers. It reports whether the worker was removed.
+func (p *Pool[T, R]) retire(w *poolWorker[T]) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.quit:
+		// The workers are being stopped by Shutdown, which closes
+		// their queues.
+		return false
+	default:
+	}
+	if len(p.workers) <= p.cfg.min || atomic.LoadInt64(&w.queued) > 0 {
+		return false
+	}
+	for i, o := range p.workers {
+		if o == w {
+			p.workers = append(p.workers[:i:i], p.workers[i+1:]...)
+			break
+		}
+	}
+	s := w.stats()
+	p.retired.Processed += s.Processed
+	p.retired.Stolen += s.Stolen
+	atomic.AddInt64(&p.idle, -1)
+	w.queue.Close()
+	return true
+}
+
+// drain marks all submitted values as processed.
+func (p *Pool[T, R]) drain() { p.once.Do(func() { close(p.drained) }) }
+
+// work is the processing loop of a worker.
+func (p *Pool[T, R]) work(w *poolWorker[T]) {
+	defer p.wg.Done()
+
+	for {
+		v, owner, ok := p.take(w)
+		if !ok {
+			var exit bool
+			v, ok, exit = p.wait(w)
+			if exit {
+				return
+			}
+			if !ok {
+				continue
+			}
+			owner = w
+		}
+		if !p.process(w, owner, v) {
+			return
+		}
+	}
+}
+
+// wait waits until the given idle worker receives a value from its
+// local queue, or is woken up to steal a value. It reports whether
+// the worker should exit.
+func (p *Pool[T, R]) wait(w *poolWorker[T]) (v T, ok, exit bool) {
+	var timeout <-chan time.Time
+	if p.cfg.idle > 0 {
+		t := time.NewTimer(p.cfg.idle)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case v, ok = <-w.queue.Out():
+		return v, ok, !ok
+	case <-p.notify:
+		return v, false, false
+	case <-timeout:
+		return v, false, p.retire(w)
+	case <-p.quit:
+		return v, false, true
+	}
+}
+
+// take receives a value from the local queue of the given worker, or
+// steals one from a peer in random order if the local queue is empty.
+// It returns the worker whose queue the value was received from.
+func (p *Pool[T, R]) take(w *poolWorker[T]) (v T, owner *poolWorker[T], ok bool) {
+	select {
+	case v, ok = <-w.queue.Out():
+		if ok {
+			return v, w, true
+		}
+	default:
+	}
+
+	p.mu.Lock()
+	peers := p.workers
+	p.mu.Unlock()
+
+	if len(peers) == 0 {
+		return v, nil, false
+	}
+	start := rand.Intn(len(peers))
+	for k := range peers {
+		peer := peers[(start+k)%len(peers)]
+		if peer == w || atomic.LoadInt64(&peer.queued) == 0 {
+			continue
+		}
+		select {
+		case v, ok = <-peer.queue.Out():
+			if ok {
+				atomic.AddUint64(&w.stolen, 1)
+				return v, peer, true
+			}
+		default:
+		}
+	}
+	return v, nil, false
+}
+
+// process applies the function of the Pool to the given value, which
+// was received from the queue of owner, and sends the result. It
+// reports whether the worker should continue.
+func (p *Pool[T, R]) process(w, owner *poolWorker[T], v T) bool {
+	atomic.AddInt64(&owner.queued, -1)
+	atomic.AddInt64(&p.idle, -1)
+	defer atomic.AddInt64(&p.idle, 1)
+
+	r := p.fn(v)
+	select {
+	case p.results.In() <- r:
+	case <-p.quit:
+		return false
+	}
+	atomic.AddUint64(&w.processed, 1)
+	if atomic.AddInt64(&p.pending, -1) == 0 {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			p.drain()
+		}
+	}
+	return true
+}
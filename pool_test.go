@@ -0,0 +1,132 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestPool(t *testing.T) {
+	p := chann.NewPool(func(v int) int { return v * v }, chann.PoolWorkers(4))
+
+	done := make(chan int)
+	go func() {
+		sum := 0
+		for r := range p.Results().Out() {
+			sum += r
+		}
+		done <- sum
+	}()
+	want := 0
+	for i := 0; i < 1000; i++ {
+		if !p.Submit(i) {
+			t.Fatalf("submit failed before shutdown")
+		}
+		want += i * i
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error, got %v want %v", err, nil)
+	}
+	if p.Submit(0) {
+		t.Fatalf("submit succeeded after shutdown")
+	}
+	if sum := <-done; sum != want {
+		t.Fatalf("unexpected sum, got %v want %v", sum, want)
+	}
+
+	// The final stats are kept after shutdown.
+	processed := uint64(0)
+	for _, s := range p.Stats() {
+		processed += s.Processed
+	}
+	if processed != 1000 {
+		t.Fatalf("unexpected number of processed values, got %v want %v", processed, 1000)
+	}
+	if n := p.Totals().Processed; n != 1000 {
+		t.Fatalf("unexpected total of processed values, got %v want %v", n, 1000)
+	}
+}
+
+func TestPoolStealing(t *testing.T) {
+	block := make(chan struct{})
+	p := chann.NewPool(func(v int) int {
+		if v == 0 {
+			<-block
+		}
+		return v
+	}, chann.PoolWorkers(2), chann.Cap(-1))
+
+	// The first value blocks the worker that takes it, and the values
+	// queued behind it must be stolen by the other worker.
+	for i := 0; i < 10; i++ {
+		p.Submit(i)
+	}
+	for i := 0; i < 9; i++ {
+		<-p.Results().Out()
+	}
+	stolen := uint64(0)
+	for _, s := range p.Stats() {
+		stolen += s.Stolen
+	}
+	if stolen == 0 {
+		t.Fatalf("no value is stolen")
+	}
+	close(block)
+	<-p.Results().Out()
+	p.Shutdown(context.Background())
+}
+
+func TestPoolAutoscale(t *testing.T) {
+	block := make(chan struct{})
+	p := chann.NewPool(func(v int) int {
+		<-block
+		return v
+	}, chann.PoolAutoscale(1, 4, 10*time.Millisecond), chann.Cap(-1))
+
+	for i := 0; i < 10; i++ {
+		p.Submit(i)
+	}
+	if n := len(p.Stats()); n != 4 {
+		t.Fatalf("unexpected number of workers, got %v want %v", n, 4)
+	}
+	close(block)
+	for i := 0; i < 10; i++ {
+		<-p.Results().Out()
+	}
+	for i := 0; i < 100 && len(p.Stats()) > 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(p.Stats()); n != 1 {
+		t.Fatalf("unexpected number of workers, got %v want %v", n, 1)
+	}
+	// The retired workers are still counted in the totals.
+	if n := p.Totals().Processed; n != 10 {
+		t.Fatalf("unexpected total of processed values, got %v want %v", n, 10)
+	}
+	p.Shutdown(context.Background())
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	p := chann.NewPool(func(v int) int { return v }, chann.PoolWorkers(2))
+	for i := 0; i < 10; i++ {
+		p.Submit(i)
+	}
+
+	// The results are never received.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error, got %v want %v", err, context.DeadlineExceeded)
+	}
+	if _, ok := <-p.Results().Out(); ok {
+		t.Fatalf("results channel is not closed after shutdown")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"time"
+)
+
+// DeadLetter is a value whose processing failed on every attempt.
+type DeadLetter[T any] struct {
+	Value    T
+	Err      error // error of the last attempt
+	Attempts int
+}
+
+// ProcessOpt represents an option to configure Process, which is
+// either ProcessWorkers, a retry option, or an Opt that configures the
+// dead-letter channel, such as Cap. The delays are measured by the
+// clock of WithClock.
+type ProcessOpt interface {
+	applyProcess(*processConfig)
+}
+
+type processConfig struct {
+	workers  int
+	attempts int
+	base     time.Duration
+	max      time.Duration
+	jitter   float64
+	opts     []Opt
+}
+
+type processOpt func(*processConfig)
+
+func (o processOpt) applyProcess(cfg *processConfig) { o(cfg) }
+func (o Opt) applyProcess(cfg *processConfig)        { cfg.opts = append(cfg.opts, o) }
+
+// ProcessWorkers configures the number of workers that call the
+// handler in parallel. By default, Process uses a single worker.
+func ProcessWorkers(n int) ProcessOpt {
+	return processOpt(func(cfg *processConfig) { cfg.workers = n })
+}
+
+// RetryAttempts configures the maximum number of attempts to process
+// a value, including the first one. By default, a value is attempted
+// three times.
+func RetryAttempts(n int) ProcessOpt {
+	return processOpt(func(cfg *processConfig) { cfg.attempts = n })
+}
+
+// RetryBackoff configures the delay before retrying a value, which is
+// base after the first attempt, and doubles after each further attempt
+// up to max. By default, the delay starts at 100ms and is at most 10s.
+func RetryBackoff(base, max time.Duration) ProcessOpt {
+	return processOpt(func(cfg *processConfig) { cfg.base, cfg.max = base, max })
+}
+
+// RetryJitter configures the fraction, between 0 and 1, by which each
+// delay is randomly shortened, so that the values that failed together
+// are not retried together. By default, the fraction is 0.2.
+func RetryJitter(f float64) ProcessOpt {
+	return processOpt(func(cfg *processConfig) { cfg.jitter = f })
+}
+
+// Process calls the handler for every value received from in. If the
+// handler returns an error, the value is retried after an exponential
+// backoff with jitter, as configured by RetryBackoff and RetryJitter,
+// until the handler succeeds or RetryAttempts is reached. The values
+// that fail on every attempt are sent to the returned dead-letter
+// channel, which is unbuffered by default and must be drained, as
+// processing waits for a dead letter to be received before going on.
+//
+// The values that wait for a retry are kept by a single goroutine, and
+// take precedence over the values of in once they are due. The values
+// are received from in only as fast as the workers take them. The
+// context passed to the handler is done once Process is stopped.
+//
+// The returned Handle reports by Wait the error of the context if it
+// is done before all values are processed. The dead-letter channel is
+// closed once in is closed and all values are processed, or once the
+// context is done.
+func Process[T any](ctx context.Context, in *Chann[T], handler func(context.Context, T) error, opts ...ProcessOpt) (*Chann[DeadLetter[T]], *Handle) {
+	cfg := processConfig{
+		workers:  1,
+		attempts: 3,
+		base:     100 * time.Millisecond,
+		max:      10 * time.Second,
+		jitter:   0.2,
+	}
+	for _, o := range opts {
+		o.applyProcess(&cfg)
+	}
+	if cfg.workers < 1 {
+		cfg.workers = 1
+	}
+	if cfg.attempts < 1 {
+		cfg.attempts = 1
+	}
+
+	type result struct {
+		t   *retryTask[T]
+		err error
+	}
+	var (
+		dead, clock = newOutput[DeadLetter[T]](cfg.opts)
+		jobs        = make(chan *retryTask[T])
+		results     = make(chan result)
+	)
+	ctx, h := newHandle(ctx)
+
+	for i := 0; i < cfg.workers; i++ {
+		h.goFunc(func() error {
+			for {
+				select {
+				case t, ok := <-jobs:
+					if !ok {
+						return nil
+					}
+					err := handler(ctx, t.v)
+					select {
+					case results <- result{t: t, err: err}:
+					case <-ctx.Done():
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+	}
+
+	// The scheduler dispatches the values to the workers, and keeps
+	// the values that wait for a retry in a heap ordered by due time,
+	// with a single timer for the earliest one.
+	h.goFunc(func() error {
+		defer close(jobs)
+
+		var (
+			ready   []*retryTask[T]
+			delayed retryHeap[T]
+			busy    int
+			closed  bool
+			timer   Timer
+			expired <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		schedule := func(now time.Time) {
+			if len(delayed) == 0 {
+				return
+			}
+			d := delayed[0].due.Sub(now)
+			if timer == nil {
+				timer = clock.NewTimer(d)
+			} else {
+				resetTimer(timer, expired != nil, d)
+			}
+			expired = timer.C()
+		}
+		for !closed || len(ready) > 0 || len(delayed) > 0 || busy > 0 {
+			var (
+				recv <-chan T
+				send chan *retryTask[T]
+				next *retryTask[T]
+			)
+			if len(ready) > 0 {
+				send, next = jobs, ready[0]
+			} else if !closed {
+				recv = in.Out()
+			}
+			select {
+			case v, ok := <-recv:
+				if !ok {
+					closed = true
+					continue
+				}
+				ready = append(ready, &retryTask[T]{v: v})
+			case send <- next:
+				ready[0] = nil
+				ready = ready[1:]
+				busy++
+			case res := <-results:
+				busy--
+				if res.err == nil {
+					continue
+				}
+				t := res.t
+				t.attempts++
+				if t.attempts >= cfg.attempts {
+					select {
+					case dead.In() <- DeadLetter[T]{Value: t.v, Err: res.err, Attempts: t.attempts}:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+					continue
+				}
+				now := clock.Now()
+				t.due = now.Add(cfg.backoff(t.attempts))
+				heap.Push(&delayed, t)
+				schedule(now)
+			case <-expired:
+				expired = nil
+				now := clock.Now()
+				for len(delayed) > 0 && !delayed[0].due.After(now) {
+					ready = append(ready, heap.Pop(&delayed).(*retryTask[T]))
+				}
+				schedule(now)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	h.start(dead.Close)
+	return dead, h
+}
+
+// backoff returns the delay before retrying a value that failed the
+// given number of attempts.
+func (cfg *processConfig) backoff(attempts int) time.Duration {
+	d := cfg.base
+	for i := 1; i < attempts && d < cfg.max; i++ {
+		d *= 2
+	}
+	if d > cfg.max {
+		d = cfg.max
+	}
+	if cfg.jitter > 0 {
+		d -= time.Duration(cfg.jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retryTask is a value to be processed, which is due at the given time
+// if it waits for a retry.
+type retryTask[T any] struct {
+	v        T
+	attempts int
+	due      time.Time
+}
+
+// retryHeap is a min-heap of the tasks that wait for a retry, ordered
+// by due time.
+type retryHeap[T any] []*retryTask[T]
+
+func (h retryHeap[T]) Len() int           { return len(h) }
+func (h retryHeap[T]) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+func (h retryHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *retryHeap[T]) Push(x any)        { *h = append(*h, x.(*retryTask[T])) }
+func (h *retryHeap[T]) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return t
+}
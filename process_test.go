@@ -0,0 +1,119 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestProcess(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts = map[int]int{}
+		errFail  = errors.New("fail")
+	)
+	handler := func(_ context.Context, v int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[v]++
+		// 1 succeeds on its third attempt, and 2 never succeeds.
+		if v == 2 || attempts[v] < 3 {
+			return errFail
+		}
+		return nil
+	}
+	attemptsOf := func(v int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts[v]
+	}
+
+	clock := newFakeClock()
+	dead, h := chann.Process(context.Background(), sendAll(1, 2), handler,
+		chann.RetryAttempts(3), chann.RetryBackoff(time.Second, 10*time.Second),
+		chann.RetryJitter(0), chann.WithClock(clock))
+
+	clock.Wait(4) // Now and NewTimer or Reset for the failures of 1 and 2
+	clock.Advance(time.Second)
+	clock.Wait(9) // Now for the tick, and Now and Reset for the failures
+
+	// The delay doubles after each attempt.
+	clock.Advance(time.Second)
+	if n := attemptsOf(1); n != 2 {
+		t.Fatalf("value is retried too early, got %v attempts want 2", n)
+	}
+	clock.Advance(time.Second)
+
+	want := chann.DeadLetter[int]{Value: 2, Err: errFail, Attempts: 3}
+	if got := chann.Collect(dead); !reflect.DeepEqual(got, []chann.DeadLetter[int]{want}) {
+		t.Fatalf("unexpected dead letters, got %v want %v", got, want)
+	}
+	if err := h.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := attemptsOf(1); n != 3 {
+		t.Fatalf("unexpected attempts, got %v want 3", n)
+	}
+}
+
+func TestProcessStop(t *testing.T) {
+	in := chann.New[int]()
+	in.In() <- 1
+	started := make(chan struct{})
+	dead, h := chann.Process(context.Background(), in, func(ctx context.Context, v int) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, chann.ProcessWorkers(2))
+
+	<-started
+	h.Stop()
+	if err := h.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error, got %v want %v", err, context.Canceled)
+	}
+	expectClosed(t, dead)
+}
+
+func TestProcessWorkers(t *testing.T) {
+	const n = 100
+	in := chann.New[int](chann.Cap(n))
+	for i := 0; i < n; i++ {
+		in.In() <- i
+	}
+	in.Close()
+
+	var (
+		mu   sync.Mutex
+		seen = map[int]bool{}
+	)
+	dead, h := chann.Process(context.Background(), in, func(_ context.Context, v int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !seen[v] {
+			seen[v] = true
+			return errors.New("first attempt")
+		}
+		return nil
+	}, chann.ProcessWorkers(4), chann.RetryBackoff(time.Millisecond, time.Millisecond))
+
+	if got := chann.Collect(dead); len(got) != 0 {
+		t.Fatalf("unexpected dead letters: %v", got)
+	}
+	if err := h.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != n {
+		t.Fatalf("unexpected processed values, got %v want %v", len(seen), n)
+	}
+}
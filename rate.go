@@ -0,0 +1,259 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"time"
+)
+
+// tokenBucket is a token bucket that is refilled with a token every
+// interval, and holds up to burst tokens. It is implemented by tracking
+// the time at which the bucket would be full again, as a sequence of
+// reservations of single tokens.
+type tokenBucket struct {
+	clock    Clock
+	interval time.Duration
+	burst    int
+	full     time.Time
+}
+
+func newTokenBucket(c Clock, r float64, burst int) *tokenBucket {
+	if r <= 0 || burst <= 0 {
+		panic("chann: non-positive rate limit")
+	}
+	return &tokenBucket{
+		clock:    c,
+		interval: time.Duration(float64(time.Second) / r),
+		burst:    burst,
+	}
+}
+
+// reserve takes a token, and returns how long to wait until the token
+// is available. It returns a non-positive duration if the token is
+// available now.
+func (b *tokenBucket) reserve() time.Duration {
+	now := b.clock.Now()
+	if b.full.Before(now) {
+		b.full = now
+	}
+	b.full = b.full.Add(b.interval)
+	return b.full.Sub(now) - time.Duration(b.burst)*b.interval
+}
+
+// rateProcessing is a processing loop that forwards the values of the
+// channel to its rate limited output. Once the channel is closed, it
+// only forwards the values to pending receivers, and drops the others.
+func (ch *Chann[T]) rateProcessing() {
+	b := newTokenBucket(ch.cfg.clock, ch.cfg.rate, ch.cfg.burst)
+	var timer Timer
+	for v := range ch.out {
+		if d := b.reserve(); d > 0 {
+			if timer == nil {
+				timer = ch.cfg.clock.NewTimer(d)
+			} else {
+				timer.Reset(d)
+			}
+			<-timer.C()
+		}
+		select {
+		case ch.lim <- v:
+		case <-ch.quit:
+			// The default branch guarantees that the loop
+			// terminates after Close, as for unboundedTerminate.
+			select {
+			case ch.lim <- v:
+			default:
+			}
+		}
+	}
+	close(ch.lim)
+}
+
+// Throttle returns a channel that receives the values received from
+// in, no faster than r values per second on average, with bursts of up
+// to burst values. The values are delayed rather than dropped.
+//
+// Once in is closed, the remaining values are sent at the same rate,
+// and the returned channel is closed. Once the context is done, the
+// returned channel is closed without sending the delayed value. The
+// options configure the output channel, and WithClock configures the
+// clock of the operator.
+//
+// Throttle panics if r or burst is not positive.
+func Throttle[T any](ctx context.Context, in *Chann[T], r float64, burst int, opts ...Opt) *Chann[T] {
+	out, clock := newOutput[T](opts)
+	b := newTokenBucket(clock, r, burst)
+	go func() {
+		defer out.Close()
+
+		var timer Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			var v T
+			select {
+			case x, ok := <-in.Out():
+				if !ok {
+					return
+				}
+				v = x
+			case <-ctx.Done():
+				return
+			}
+			if d := b.reserve(); d > 0 {
+				if timer == nil {
+					timer = clock.NewTimer(d)
+				} else {
+					timer.Reset(d)
+				}
+				select {
+				case <-timer.C():
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case out.In() <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Debounce returns a channel that receives the last value received
+// from in once no value arrives for the duration d. The values that
+// are followed by another value within d are dropped.
+//
+// Once in is closed, the last value is sent if it has not been, and
+// the returned channel is closed. Once the context is done, the
+// returned channel is closed without sending the last value. The
+// options configure the output channel, and WithClock configures the
+// clock of the operator.
+func Debounce[T any](ctx context.Context, in *Chann[T], d time.Duration, opts ...Opt) *Chann[T] {
+	if d <= 0 {
+		panic("chann: non-positive debounce duration")
+	}
+	out, clock := newOutput[T](opts)
+	go func() {
+		defer out.Close()
+
+		var (
+			last    T
+			pending bool
+			timer   Timer
+			expired <-chan time.Time
+		)
+		emit := func() bool {
+			v := last
+			last, pending = *new(T), false
+			select {
+			case out.In() <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if pending {
+						emit()
+					}
+					return
+				}
+				last, pending = v, true
+				if timer == nil {
+					timer = clock.NewTimer(d)
+				} else {
+					resetTimer(timer, expired != nil, d)
+				}
+				expired = timer.C()
+			case <-expired:
+				expired = nil
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Sample returns a channel that receives the latest value received
+// from in every duration d. Nothing is sent if no value arrives since
+// the last sample, and the other values are dropped.
+//
+// Once in is closed, the latest value is sent if it has not been, and
+// the returned channel is closed. Once the context is done, the
+// returned channel is closed without sending the latest value. The
+// options configure the output channel, and WithClock configures the
+// clock of the operator.
+func Sample[T any](ctx context.Context, in *Chann[T], d time.Duration, opts ...Opt) *Chann[T] {
+	if d <= 0 {
+		panic("chann: non-positive sample duration")
+	}
+	out, clock := newOutput[T](opts)
+	go func() {
+		defer out.Close()
+
+		var (
+			last    T
+			pending bool
+		)
+		emit := func() bool {
+			v := last
+			last, pending = *new(T), false
+			select {
+			case out.In() <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// The samples are taken at fixed times, regardless of when
+		// the timer actually fires.
+		next := clock.Now().Add(d)
+		timer := clock.NewTimer(d)
+		defer timer.Stop()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if pending {
+						emit()
+					}
+					return
+				}
+				last, pending = v, true
+			case <-timer.C():
+				next = next.Add(d)
+				timer.Reset(next.Sub(clock.Now()))
+				if pending && !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func expectValue(t *testing.T, out *chann.Chann[int], want int) {
+	t.Helper()
+	if got, ok := <-out.Out(); !ok || got != want {
+		t.Fatalf("unexpected value, got %v/%v want %v", got, ok, want)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int]()
+	out := chann.Throttle(context.Background(), in, 1, 2, chann.WithClock(clock))
+	for i := 0; i < 4; i++ {
+		in.In() <- i
+	}
+
+	// The first values are sent at once, up to the burst.
+	expectValue(t, out, 0)
+	expectValue(t, out, 1)
+	clock.Wait(4) // Now for 0, 1 and 2, and NewTimer for 2
+	select {
+	case v := <-out.Out():
+		t.Fatalf("value %v is sent before its token", v)
+	default:
+	}
+	clock.Advance(time.Second)
+	expectValue(t, out, 2)
+	clock.Wait(6) // Now and Reset for 3
+	clock.Advance(time.Second)
+	expectValue(t, out, 3)
+
+	in.Close()
+	expectClosed(t, out)
+}
+
+func TestDebounce(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.Debounce(context.Background(), in, time.Second, chann.WithClock(clock))
+
+	in.In() <- 1
+	clock.Wait(1) // NewTimer
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- 2
+	clock.Wait(2) // Reset
+	clock.Advance(time.Second)
+	expectValue(t, out, 2)
+
+	// The last value is sent once the input is closed.
+	in.In() <- 3
+	in.Close()
+	expectValue(t, out, 3)
+	expectClosed(t, out)
+}
+
+func TestSample(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.Sample(context.Background(), in, time.Second, chann.WithClock(clock))
+	clock.Wait(2) // Now and NewTimer
+
+	in.In() <- 1
+	in.In() <- 2
+	clock.Advance(time.Second)
+	expectValue(t, out, 2)
+
+	// Nothing is sent if no value arrives since the last sample.
+	clock.Wait(4) // Now and Reset for the tick
+	clock.Advance(time.Second)
+	clock.Wait(6) // Now and Reset for the tick
+	in.In() <- 3
+	clock.Advance(time.Second)
+	expectValue(t, out, 3)
+
+	in.In() <- 4
+	in.Close()
+	expectValue(t, out, 4)
+	expectClosed(t, out)
+}
+
+func TestThrottleContext(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := chann.New[int]()
+	out := chann.Throttle(ctx, in, 1, 1, chann.WithClock(clock))
+	in.In() <- 1
+	in.In() <- 2
+	expectValue(t, out, 1)
+
+	// The delayed value is not sent once the context is done.
+	clock.Wait(3) // Now for 1 and 2, and NewTimer for 2
+	cancel()
+	expectClosed(t, out)
+}
+
+func TestRateLimit(t *testing.T) {
+	const n = 5
+	for _, opt := range []chann.Opt{chann.Cap(0), chann.Cap(n), chann.Cap(-1)} {
+		ch := chann.New[int](opt, chann.RateLimit(100, 1))
+		go func() {
+			for i := 0; i < n; i++ {
+				ch.In() <- i
+			}
+			ch.Close()
+		}()
+
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			if v := <-ch.Out(); v != i {
+				t.Fatalf("unexpected value, got %v want %v", v, i)
+			}
+		}
+		if _, ok := <-ch.Out(); ok {
+			t.Fatalf("channel is not closed")
+		}
+		if d := time.Since(start); d < (n-1)*10*time.Millisecond {
+			t.Fatalf("values are received too fast, %v values in %v", n, d)
+		}
+	}
+
+	// The closed channels that nobody receives from do not leak their
+	// goroutines.
+	grs := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		for _, opt := range []chann.Opt{chann.Cap(0), chann.Cap(n), chann.Cap(-1)} {
+			ch := chann.New[int](opt, chann.RateLimit(1000, 1))
+			ch.In() <- 0
+			ch.Close()
+		}
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > grs; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > grs {
+		t.Fatalf("leaking goroutines, got %v want %v", n, grs)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import "context"
+
+// Result is either a value or an error, which allows a channel to carry
+// the errors of a stream in band with its values. As a Result is an
+// ordinary value, a channel of Results works with all helpers of the
+// package, such as Fanin or Ranger.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// Ok returns a Result of the value v.
+func Ok[T any](v T) Result[T] { return Result[T]{Value: v} }
+
+// Err returns a Result of the error err.
+func Err[T any](err error) Result[T] { return Result[T]{Err: err} }
+
+// Try returns a Result of the value and the error returned by fn. If
+// fn returns an error, the value is discarded.
+func Try[T any](fn func() (T, error)) Result[T] {
+	v, err := fn()
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(v)
+}
+
+// Get returns the value and the error of the Result.
+func (r Result[T]) Get() (T, error) { return r.Value, r.Err }
+
+// Unwrap returns two channels, where values receives the values of the
+// Results received from in, and errs receives their errors. Both
+// channels are unbuffered, and behave as the channels of Partition.
+func Unwrap[T any](in *Chann[Result[T]]) (values *Chann[T], errs *Chann[error]) {
+	oks, fails := Partition(in, func(r Result[T]) bool { return r.Err == nil })
+	values, errs = New[T](Cap(0)), New[error](Cap(0))
+	go func() {
+		for r := range oks.Out() {
+			values.In() <- r.Value
+		}
+		values.Close()
+	}()
+	go func() {
+		for r := range fails.Out() {
+			errs.In() <- r.Err
+		}
+		errs.Close()
+	}()
+	return values, errs
+}
+
+// Results returns a channel that receives a Result of every value
+// received by the Receiver, followed by a Result of the error of the
+// Sender, if it is closed by CloseWithError. The returned channel is
+// closed once the Sender is closed, and should be drained, as the
+// values are received by a separate goroutine.
+func Results[T any](r *Receiver[T]) *Chann[Result[T]] {
+	out := New[Result[T]](Cap(0))
+	go func() {
+		defer out.Close()
+		for {
+			v, ok := r.Next()
+			if !ok {
+				break
+			}
+			out.In() <- Ok(v)
+		}
+		if err := r.Err(); err != nil {
+			out.In() <- Err[T](err)
+		}
+	}()
+	return out
+}
+
+// The operators below short-circuit on the first error: they send the
+// first Result of an error, and then close their output without
+// receiving from their input anymore. The context of the upstream
+// operators should be canceled to stop them as well. Otherwise, they
+// behave as the stream operators.
+
+// TryMap returns a channel that receives a Result of fn(v) for every
+// value v of the Results received from in, until a Result of in or fn
+// holds an error.
+func TryMap[T, R any](ctx context.Context, in *Chann[Result[T]], fn func(T) (R, error), opts ...Opt) *Chann[Result[R]] {
+	return stream(ctx, in, opts, func(r Result[T], emit func(Result[R]) bool) bool {
+		if r.Err != nil {
+			emit(Err[R](r.Err))
+			return false
+		}
+		v, err := fn(r.Value)
+		if err != nil {
+			emit(Err[R](err))
+			return false
+		}
+		return emit(Ok(v))
+	}, nil)
+}
+
+// UntilErr returns a channel that receives the Results received from
+// in, until a Result holds an error.
+func UntilErr[T any](ctx context.Context, in *Chann[Result[T]], opts ...Opt) *Chann[Result[T]] {
+	return stream(ctx, in, opts, func(r Result[T], emit func(Result[T]) bool) bool {
+		return emit(r) && r.Err == nil
+	}, nil)
+}
+
+// CollectResults receives the Results from in until it is closed, and
+// returns their values in the order of receiving. It returns the first
+// error instead, as soon as a Result holds one, without receiving the
+// remaining Results. In that case, the caller should cancel the context
+// of the upstream operators or drain in, so that they do not block
+// forever on sending to in.
+func CollectResults[T any](in *Chann[Result[T]]) ([]T, error) {
+	var vs []T
+	for r := range in.Out() {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		vs = append(vs, r.Value)
+	}
+	return vs, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+func TestTry(t *testing.T) {
+	if v, err := chann.Try(func() (int, error) { return strconv.Atoi("42") }).Get(); v != 42 || err != nil {
+		t.Fatalf("unexpected result, got %v/%v want 42/nil", v, err)
+	}
+	if v, err := chann.Try(func() (int, error) { return strconv.Atoi("x") }).Get(); v != 0 || err == nil {
+		t.Fatalf("unexpected result, got %v/%v want an error", v, err)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	errFail := errors.New("fail")
+	in := sendAll(chann.Ok(1), chann.Err[int](errFail), chann.Ok(2))
+	values, errs := chann.Unwrap(in)
+
+	// The errors are not blocked by the values that are not received.
+	if err := <-errs.Out(); err != errFail {
+		t.Fatalf("unexpected error, got %v want %v", err, errFail)
+	}
+	expectClosed(t, errs)
+	if got := chann.Collect(values); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("unexpected values, got %v want %v", got, []int{1, 2})
+	}
+}
+
+func TestTryMap(t *testing.T) {
+	in := sendAll(chann.Ok("1"), chann.Ok("2"), chann.Ok("x"), chann.Ok("3"))
+	out := chann.TryMap(context.Background(), in, strconv.Atoi)
+
+	vs, err := chann.CollectResults(out)
+	if err == nil || vs != nil {
+		t.Fatalf("unexpected result, got %v/%v want an error", vs, err)
+	}
+	// The values after the error are not received.
+	if v := <-in.Out(); v.Value != "3" {
+		t.Fatalf("unexpected value left, got %v want 3", v.Value)
+	}
+}
+
+func TestUntilErr(t *testing.T) {
+	errFail := errors.New("fail")
+	in := sendAll(chann.Ok(1), chann.Err[int](errFail), chann.Ok(2))
+	out := chann.UntilErr(context.Background(), in)
+	want := []chann.Result[int]{chann.Ok(1), chann.Err[int](errFail)}
+	if got := chann.Collect(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected results, got %v want %v", got, want)
+	}
+}
+
+func TestResultsFanin(t *testing.T) {
+	errFail := errors.New("fail")
+	s1, r1 := chann.Ranger[int]()
+	s2, r2 := chann.Ranger[int]()
+	go func() {
+		s1.Send(1)
+		s1.Send(2)
+		s1.Close()
+	}()
+	go func() {
+		s2.Send(3)
+		s2.CloseWithError(errFail)
+	}()
+
+	// The error of a Sender arrives in band with the values.
+	var (
+		vs   []int
+		errs []error
+	)
+	for r := range chann.Fanin(chann.Results(r1), chann.Results(r2)).Out() {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			continue
+		}
+		vs = append(vs, r.Value)
+	}
+	sort.Ints(vs)
+	if !reflect.DeepEqual(vs, []int{1, 2, 3}) || !reflect.DeepEqual(errs, []error{errFail}) {
+		t.Fatalf("unexpected results, got %v/%v", vs, errs)
+	}
+
+	vs, err := chann.CollectResults(sendAll(chann.Ok(1), chann.Ok(2)))
+	if err != nil || !reflect.DeepEqual(vs, []int{1, 2}) {
+		t.Fatalf("unexpected result, got %v/%v want [1 2]/nil", vs, err)
+	}
+}
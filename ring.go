@@ -0,0 +1,118 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// defaultReplicas is the number of virtual nodes of each node on
+// a hashRing, which is enough to spread the keys evenly over a few
+// dozen nodes.
+const defaultReplicas = 128
+
+// hashRing is a consistent-hash ring that maps keys to nodes. Each
+// node is placed on the ring as a number of virtual nodes, and a key
+// belongs to the first virtual node that follows the hash of the key.
+// Adding or removing a node only remaps the keys of that node.
+type hashRing[N comparable] struct {
+	replicas int
+	id       func(N) string
+	points   []ringPoint[N]
+}
+
+type ringPoint[N comparable] struct {
+	hash uint64
+	node N
+}
+
+// newHashRing returns an empty ring. Each node is placed by replicas
+// virtual nodes, whose positions are derived from the id of the node.
+func newHashRing[N comparable](replicas int, id func(N) string) *hashRing[N] {
+	return &hashRing[N]{replicas: replicas, id: id}
+}
+
+// add places the given node on the ring.
+func (r *hashRing[N]) add(n N) {
+	id := r.id(n)
+	for i := 0; i < r.replicas; i++ {
+		h := hashString(id + "#" + strconv.Itoa(i))
+		r.points = append(r.points, ringPoint[N]{hash: h, node: n})
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+}
+
+// remove removes the given node from the ring.
+func (r *hashRing[N]) remove(n N) {
+	points := r.points[:0]
+	for _, p := range r.points {
+		if p.node != n {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+}
+
+// get returns the node that the given hash belongs to. It panics if
+// the ring is empty.
+func (r *hashRing[N]) get(h uint64) N {
+	if len(r.points) == 0 {
+		panic("chann: empty hash ring")
+	}
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// hashKey returns the hash of the given key. Keys of string and
+// integer types are hashed directly, and other keys are hashed by
+// their default format, hence equal keys have equal hashes.
+func hashKey[K comparable](k K) uint64 {
+	var s string
+	switch v := any(k).(type) {
+	case string:
+		s = v
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case int32:
+		s = strconv.FormatInt(int64(v), 10)
+	case uint:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(v), 10)
+	default:
+		s = fmt.Sprintf("%#v", v)
+	}
+	return hashString(s)
+}
+
+// hashString returns the FNV-1a hash of the given string, followed by
+// a finalizer that spreads similar strings over the whole ring.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
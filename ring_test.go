@@ -0,0 +1,37 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"strconv"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+func TestHashRing(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	before := chann.HashRing([]string{"a", "b", "c", "d", "e"}, keys)
+	after := chann.HashRing([]string{"a", "b", "c", "d"}, keys)
+
+	count := map[string]int{}
+	for i := range keys {
+		count[before[i]]++
+		if before[i] != "e" && after[i] != before[i] {
+			t.Fatalf("key %v is remapped from %v to %v", keys[i], before[i], after[i])
+		}
+	}
+	// Each node should own about a fifth of the keys.
+	for n, c := range count {
+		if c < 1000 || c > 3000 {
+			t.Fatalf("node %v is unbalanced, got %v keys", n, c)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Select waits until one of the given channels is ready to receive,
+// or the given context is done. It returns the index of the chosen
+// channel, the received value, and whether the value was delivered
+// by a send rather than a close, as the two-value form of a receive
+// operation does. If more than one channel is ready, one is chosen
+// uniformly at random.
+//
+// If the context is done first, Select returns -1 as the index.
+func Select[T any](ctx context.Context, chans ...*Chann[T]) (idx int, v T, ok bool) {
+	cases := make([]reflect.SelectCase, 0, len(chans)+1)
+	for _, ch := range chans {
+		cases = append(cases, recvCase(ch.Out()))
+	}
+	if done := ctx.Done(); done != nil {
+		cases = append(cases, recvCase(done))
+	}
+
+	i, rv, ok := reflect.Select(cases)
+	if i == len(chans) {
+		return -1, v, false
+	}
+	if ok {
+		// The assertion may fail only if T is an interface type and
+		// the received value is nil, where v is already the nil value.
+		v, _ = rv.Interface().(T)
+	}
+	return i, v, ok
+}
+
+// A Selector waits on a set of channels that may change over time.
+// A Selector is safe for concurrent use, and channels can be added or
+// removed while another goroutine is waiting in Select, with the caveat
+// described by Remove.
+//
+// The zero value is an empty Selector ready to use.
+type Selector[T any] struct {
+	mu      sync.Mutex
+	chans   []*Chann[T]
+	changed chan struct{}
+}
+
+// NewSelector returns a Selector that waits on the given channels.
+func NewSelector[T any](chans ...*Chann[T]) *Selector[T] {
+	s := &Selector[T]{}
+	for _, ch := range chans {
+		s.Add(ch)
+	}
+	return s
+}
+
+// Add adds the given channel to the Selector. Adding a channel that
+// is already in the Selector has no effect.
+func (s *Selector[T]) Add(ch *Chann[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.chans {
+		if c == ch {
+			return
+		}
+	}
+	s.chans = append(s.chans, ch)
+	s.notify()
+}
+
+// Remove removes the given channel from the Selector, and reports
+// whether the channel was in the Selector.
+//
+// The Select calls that start after Remove returns do not receive from
+// the channel anymore. However, a Select that is already waiting may
+// still receive a value from the channel and return it, if the channel
+// is ready when the Select is woken up to observe the removal, as the
+// value is received before the removal is noticed.
+func (s *Selector[T]) Remove(ch *Chann[T]) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remove(ch)
+}
+
+// Len returns the number of channels in the Selector.
+func (s *Selector[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.chans)
+}
+
+// Select waits until one of the channels in the Selector is ready to
+// receive, or the given context is done. It returns the chosen channel,
+// the received value, and whether the value was delivered by a send
+// rather than a close. If more than one channel is ready, one is chosen
+// uniformly at random, hence no channel can starve the others.
+//
+// A closed channel is removed from the Selector once it is chosen.
+// If the context is done first, Select returns a nil channel.
+func (s *Selector[T]) Select(ctx context.Context) (ch *Chann[T], v T, ok bool) {
+	for {
+		s.mu.Lock()
+		chans := s.chans
+		changed := s.wait()
+		s.mu.Unlock()
+
+		cases := make([]reflect.SelectCase, 0, len(chans)+2)
+		for _, c := range chans {
+			cases = append(cases, recvCase(c.Out()))
+		}
+		cases = append(cases, recvCase(changed))
+		if done := ctx.Done(); done != nil {
+			cases = append(cases, recvCase(done))
+		}
+
+		i, rv, ok := reflect.Select(cases)
+		switch {
+		case i == len(chans):
+			continue
+		case i > len(chans):
+			return nil, v, false
+		}
+
+		ch = chans[i]
+		if !ok {
+			s.mu.Lock()
+			s.remove(ch)
+			s.mu.Unlock()
+			return ch, v, false
+		}
+		v, _ = rv.Interface().(T)
+		return ch, v, true
+	}
+}
+
+// remove removes the given channel and reports whether it was found.
+// The caller must hold s.mu.
+func (s *Selector[T]) remove(ch *Chann[T]) bool {
+	for i, c := range s.chans {
+		if c != ch {
+			continue
+		}
+		// Copy instead of modifying in place, because a concurrent
+		// Select may still refer to the old slice.
+		chans := make([]*Chann[T], 0, len(s.chans)-1)
+		chans = append(chans, s.chans[:i]...)
+		s.chans = append(chans, s.chans[i+1:]...)
+		s.notify()
+		return true
+	}
+	return false
+}
+
+// wait returns a channel that is closed when the set of channels
+// changes. The caller must hold s.mu.
+func (s *Selector[T]) wait() chan struct{} {
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	return s.changed
+}
+
+// notify wakes up all pending Select calls so that they can observe
+// the changed set of channels. The caller must hold s.mu.
+func (s *Selector[T]) notify() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// recvCase returns a receive case of the given channel for reflect.Select.
+func recvCase[T any](ch <-chan T) reflect.SelectCase {
+	return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+func TestSelect(t *testing.T) {
+	chs := make([]*chann.Chann[int], 10)
+	for i := range chs {
+		chs[i] = chann.New[int]()
+	}
+	chs[3].In() <- 42
+
+	idx, v, ok := chann.Select(context.Background(), chs...)
+	if idx != 3 || v != 42 || !ok {
+		t.Fatalf("unexpected select, got %v/%v/%v want %v/%v/%v", idx, v, ok, 3, 42, true)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if idx, _, _ := chann.Select(ctx, chs...); idx != -1 {
+		t.Fatalf("unexpected select after context is done, got %v want %v", idx, -1)
+	}
+
+	ch := chann.New[any](chann.Cap(1))
+	ch.In() <- nil
+	if _, v, ok := chann.Select(context.Background(), ch); v != nil || !ok {
+		t.Fatalf("unexpected select, got %v/%v want %v/%v", v, ok, nil, true)
+	}
+}
+
+func TestSelector(t *testing.T) {
+	s := chann.NewSelector[int]()
+
+	a := chann.New[int]()
+	b := chann.New[int]()
+	s.Add(a)
+	s.Add(a)
+	if s.Len() != 1 {
+		t.Fatalf("unexpected length, got %v want %v", s.Len(), 1)
+	}
+
+	// Adding a channel wakes up a pending Select.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Add(b)
+		b.In() <- 1
+	}()
+	ch, v, ok := s.Select(context.Background())
+	if ch != b || v != 1 || !ok {
+		t.Fatalf("unexpected select, got %v/%v want %v/%v", v, ok, 1, true)
+	}
+
+	// Both channels are ready, and both must be chosen eventually.
+	const N = 1000
+	for i := 0; i < N; i++ {
+		a.In() <- i
+		b.In() <- i
+	}
+	count := map[*chann.Chann[int]]int{}
+	for i := 0; i < N; i++ {
+		ch, _, _ := s.Select(context.Background())
+		count[ch]++
+	}
+	if count[a] == 0 || count[b] == 0 {
+		t.Fatalf("unfair select, got %v and %v", count[a], count[b])
+	}
+
+	// A closed channel is removed once chosen.
+	if !s.Remove(a) || s.Remove(a) {
+		t.Fatalf("unexpected remove result")
+	}
+	b.Close()
+	for {
+		ch, _, ok := s.Select(context.Background())
+		if ch != b {
+			t.Fatalf("unexpected channel is chosen")
+		}
+		if !ok {
+			break
+		}
+	}
+	if s.Len() != 0 {
+		t.Fatalf("unexpected length, got %v want %v", s.Len(), 0)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import "context"
+
+// The stream operators below receive values from an input channel and
+// send the derived values to a new output channel. The output channel
+// is closed once the input channel is closed, hence closing propagates
+// downstream through a chain of operators. Once the given context is
+// done, an operator stops receiving from its input and closes its
+// output, hence cancelling the context shared by a chain of operators
+// stops all of them.
+//
+// The options configure the output channel as for New, except that the
+// output channel is unbuffered by default.
+
+// Map returns a channel that receives fn(v) for every value v received
+// from in.
+func Map[T, R any](ctx context.Context, in *Chann[T], fn func(T) R, opts ...Opt) *Chann[R] {
+	return stream(ctx, in, opts, func(v T, emit func(R) bool) bool {
+		return emit(fn(v))
+	}, nil)
+}
+
+// Filter returns a channel that receives the values from in that
+// satisfy the predicate.
+func Filter[T any](ctx context.Context, in *Chann[T], pred func(T) bool, opts ...Opt) *Chann[T] {
+	return stream(ctx, in, opts, func(v T, emit func(T) bool) bool {
+		return !pred(v) || emit(v)
+	}, nil)
+}
+
+// FlatMap returns a channel that receives all values of fn(v) in order,
+// for every value v received from in.
+func FlatMap[T, R any](ctx context.Context, in *Chann[T], fn func(T) []R, opts ...Opt) *Chann[R] {
+	return stream(ctx, in, opts, func(v T, emit func(R) bool) bool {
+		for _, r := range fn(v) {
+			if !emit(r) {
+				return false
+			}
+		}
+		return true
+	}, nil)
+}
+
+// Scan returns a channel that receives every intermediate result of
+// folding the values received from in by fn, starting from init.
+func Scan[T, R any](ctx context.Context, in *Chann[T], init R, fn func(acc R, v T) R, opts ...Opt) *Chann[R] {
+	acc := init
+	return stream(ctx, in, opts, func(v T, emit func(R) bool) bool {
+		acc = fn(acc, v)
+		return emit(acc)
+	}, nil)
+}
+
+// Reduce returns a channel that receives the single result of folding
+// all values received from in by fn, starting from init, once in is
+// closed. If the context is done first, the returned channel is closed
+// without a result.
+func Reduce[T, R any](ctx context.Context, in *Chann[T], init R, fn func(acc R, v T) R, opts ...Opt) *Chann[R] {
+	acc := init
+	return stream(ctx, in, opts, func(v T, _ func(R) bool) bool {
+		acc = fn(acc, v)
+		return true
+	}, func(emit func(R) bool) {
+		emit(acc)
+	})
+}
+
+// newOutput returns the output channel of an operator created with the
+// given options, which is unbuffered unless Cap is provided, and the
+// clock of the channel, which the operator uses as well.
+func newOutput[R any](opts []Opt) (*Chann[R], Clock) {
+	out := New[R](append([]Opt{Cap(0)}, opts...)...)
+	return out, out.cfg.clock
+}
+
+// clockOf returns the clock configured by the given options, for the
+// operators that need it before creating their output channel.
+func clockOf(opts []Opt) Clock {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.clock == nil {
+		return realClock{}
+	}
+	return cfg.clock
+}
+
+// stream starts a goroutine that calls fn for every value received
+// from in, until in is closed or fn returns false, and then calls
+// finish, if not nil, unless the context is done. Both functions send
+// values to the returned channel by emit, which reports false if the
+// context is done. The returned channel is closed when the goroutine
+// ends.
+func stream[T, R any](ctx context.Context, in *Chann[T], opts []Opt, fn func(v T, emit func(R) bool) bool, finish func(emit func(R) bool)) *Chann[R] {
+	out, _ := newOutput[R](opts)
+	emit := func(r R) bool {
+		select {
+		case out.In() <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer out.Close()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if finish != nil && ctx.Err() == nil {
+						finish(emit)
+					}
+					return
+				}
+				if !fn(v, emit) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
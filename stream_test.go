@@ -0,0 +1,58 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+
+	// 0..9 -> odd -> 1, 3, 5, 7, 9 -> twice -> strings.
+	odd := chann.Filter(ctx, sendAll(numbers...), func(v int) bool { return v%2 == 1 })
+	twice := chann.FlatMap(ctx, odd, func(v int) []int { return []int{v, v} }, chann.Cap(4))
+	strs := chann.Map(ctx, twice, strconv.Itoa)
+	want := []string{"1", "1", "3", "3", "5", "5", "7", "7", "9", "9"}
+	if got := chann.Collect(strs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values, got %v want %v", got, want)
+	}
+
+	sum := func(acc, v int) int { return acc + v }
+	want2 := []int{0, 1, 3, 6, 10, 15, 21, 28, 36, 45}
+	if got := chann.Collect(chann.Scan(ctx, sendAll(numbers...), 0, sum)); !reflect.DeepEqual(got, want2) {
+		t.Fatalf("unexpected values, got %v want %v", got, want2)
+	}
+	if got := chann.Collect(chann.Reduce(ctx, sendAll(numbers...), 0, sum)); !reflect.DeepEqual(got, []int{45}) {
+		t.Fatalf("unexpected values, got %v want %v", got, []int{45})
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	grs := runtime.NumGoroutine()
+
+	// The input is never closed, and the output is never received.
+	ctx, cancel := context.WithCancel(context.Background())
+	in := chann.New[int](chann.Cap(1))
+	in.In() <- 1
+	out := chann.Map(ctx, chann.Filter(ctx, in, func(int) bool { return true }), func(v int) int { return v })
+	red := chann.Reduce(ctx, in, 0, func(acc, v int) int { return acc + v })
+	cancel()
+
+	for range out.Out() {
+	}
+	if _, ok := <-red.Out(); ok {
+		t.Fatalf("reduce sent a result after cancellation")
+	}
+	checkGoroutines(t, grs)
+}
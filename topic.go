@@ -0,0 +1,271 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import "sync"
+
+// Topic is an in-process publish/subscribe topic. Every message that
+// is published to a Topic is sent to all of its subscriptions, each of
+// which is a Chann. A Topic is safe for concurrent use.
+type Topic[T any] struct {
+	pub sync.Mutex // serializes Publish
+
+	mu        sync.Mutex
+	subs      map[*Chann[T]]*subscription[T]
+	history   []T
+	size      int
+	published uint64 // number of published messages
+	closed    bool
+}
+
+// NewTopic returns a new Topic that keeps the last history messages,
+// so that they can be replayed to late subscribers. See SubscribeReplay.
+func NewTopic[T any](history int) *Topic[T] {
+	if history < 0 {
+		history = 0
+	}
+	return &Topic[T]{
+		subs: make(map[*Chann[T]]*subscription[T]),
+		size: history,
+	}
+}
+
+// subscription is a subscriber of a Topic.
+type subscription[T any] struct {
+	policy OverflowPolicy
+	replay int
+	filter func(T) bool
+	fwd    *forwarder[T]
+
+	quit    chan struct{}  // closed once unsubscribed
+	sending sync.WaitGroup // messages being sent by Publish
+}
+
+// SubscribeOpt represents an option to configure a subscription, which
+// is either SubscribePolicy, SubscribeReplay, SubscribeFilter, or an Opt
+// that configures the subscribed channel, such as Cap.
+type SubscribeOpt interface {
+	applySubscribe(*subscribeConfig)
+}
+
+type subscribeConfig struct {
+	opts   []Opt
+	policy OverflowPolicy
+	replay int
+	filter any // func(T) bool of the Topic
+}
+
+type subscribeOpt func(*subscribeConfig)
+
+func (o subscribeOpt) applySubscribe(cfg *subscribeConfig) { o(cfg) }
+func (o Opt) applySubscribe(cfg *subscribeConfig)          { cfg.opts = append(cfg.opts, o) }
+
+// SubscribePolicy configures how the messages are handled if the
+// subscribed channel is not ready to receive them. By default, the
+// channel is unbuffered, and the messages are queued without bound by
+// the Spill policy, hence a slow subscriber never blocks publishing.
+func SubscribePolicy(policy OverflowPolicy) SubscribeOpt {
+	return subscribeOpt(func(cfg *subscribeConfig) { cfg.policy = policy })
+}
+
+// SubscribeReplay configures the subscription to first receive the
+// last n messages that were published before subscribing, up to the
+// history size of the Topic.
+func SubscribeReplay(n int) SubscribeOpt {
+	return subscribeOpt(func(cfg *subscribeConfig) { cfg.replay = n })
+}
+
+// SubscribeFilter configures the subscription to only receive the
+// messages that satisfy the given predicate. The filter also applies
+// to replayed messages. Subscribe panics if the type of the messages
+// of the predicate is not the one of the Topic.
+func SubscribeFilter[T any](pred func(T) bool) SubscribeOpt {
+	return subscribeOpt(func(cfg *subscribeConfig) { cfg.filter = pred })
+}
+
+// accept reports whether the subscription receives the given message.
+func (s *subscription[T]) accept(v T) bool {
+	return s.filter == nil || s.filter(v)
+}
+
+// Publish sends the given message to all subscriptions, and keeps it
+// in the history of the Topic. It reports whether the message was
+// published, which is false only if the Topic is closed.
+//
+// Publish waits for the subscriptions with the Block policy that are
+// not ready, and the concurrent calls of Publish wait in turn, so that
+// all subscriptions receive the messages in the same order. The other
+// methods of the Topic do not wait for Publish, and a subscription that
+// Publish waits for can be unsubscribed meanwhile.
+func (t *Topic[T]) Publish(v T) bool {
+	t.pub.Lock()
+	defer t.pub.Unlock()
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return false
+	}
+	if t.size > 0 {
+		if len(t.history) == t.size {
+			var nilT T
+			t.history[0] = nilT
+			t.history = t.history[1:]
+		}
+		t.history = append(t.history, v)
+	}
+	t.published++
+	subs := make([]*subscription[T], 0, len(t.subs))
+	for _, s := range t.subs {
+		s.sending.Add(1)
+		subs = append(subs, s)
+	}
+	t.mu.Unlock()
+
+	for _, s := range subs {
+		if s.accept(v) {
+			s.fwd.sendUntil(v, s.quit)
+		}
+		s.sending.Done()
+	}
+	return true
+}
+
+// last returns a copy of the last n messages of the history. The
+// caller must hold t.mu.
+func (t *Topic[T]) last(n int) []T {
+	if n > len(t.history) {
+		n = len(t.history)
+	}
+	if n <= 0 {
+		return nil
+	}
+	return append([]T(nil), t.history[len(t.history)-n:]...)
+}
+
+// Subscribe returns a new channel that receives the messages that are
+// published to the Topic from now on. The channel is closed when it
+// is unsubscribed or when the Topic is closed. Subscribing to a closed
+// Topic returns a closed channel.
+func (t *Topic[T]) Subscribe(opts ...SubscribeOpt) *Chann[T] {
+	cfg := subscribeConfig{policy: Spill}
+	for _, o := range opts {
+		o.applySubscribe(&cfg)
+	}
+	s := &subscription[T]{policy: cfg.policy, replay: cfg.replay, quit: make(chan struct{})}
+	if cfg.filter != nil {
+		filter, ok := cfg.filter.(func(T) bool)
+		if !ok {
+			panic("chann: mismatched type of subscription filter")
+		}
+		s.filter = filter
+	}
+	ch, _ := newOutput[T](cfg.opts)
+
+	// The messages to replay are filtered without holding the lock,
+	// hence the messages that are published meanwhile are filtered in
+	// turn, until none is published while filtering.
+	var (
+		msgs []T
+		oks  []bool
+	)
+	t.mu.Lock()
+	batch := t.last(s.replay)
+	for len(batch) > 0 {
+		published := t.published
+		t.mu.Unlock()
+		for _, v := range batch {
+			msgs = append(msgs, v)
+			oks = append(oks, s.accept(v))
+		}
+		t.mu.Lock()
+		if n := t.published - published; n < uint64(s.replay) {
+			batch = t.last(int(n))
+		} else {
+			batch = t.last(s.replay)
+		}
+	}
+	defer t.mu.Unlock()
+
+	if t.closed {
+		ch.Close()
+		return ch
+	}
+	if len(msgs) > s.replay {
+		msgs, oks = msgs[len(msgs)-s.replay:], oks[len(oks)-s.replay:]
+	}
+	var replay []T
+	for i, v := range msgs {
+		if oks[i] {
+			replay = append(replay, v)
+		}
+	}
+	s.fwd = newQueuedForwarder(s.policy, ch, replay)
+	t.subs[ch] = s
+	return ch
+}
+
+// Unsubscribe removes the given subscription from the Topic, and
+// closes its channel once the pending messages are delivered. It
+// reports whether the channel was subscribed to the Topic.
+//
+// A message that Publish is sending to the subscription meanwhile may
+// be discarded.
+func (t *Topic[T]) Unsubscribe(ch *Chann[T]) bool {
+	t.mu.Lock()
+	s, ok := t.subs[ch]
+	if ok {
+		delete(t.subs, ch)
+		close(s.quit)
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	s.unsubscribe()
+	return true
+}
+
+// unsubscribe closes the forwarder of a subscription once Publish
+// stops sending to it. The subscription must be removed from the Topic
+// and its quit channel closed first.
+func (s *subscription[T]) unsubscribe() {
+	s.sending.Wait()
+	s.fwd.close()
+}
+
+// Len returns the number of subscriptions of the Topic.
+func (t *Topic[T]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.subs)
+}
+
+// Close closes the Topic and unsubscribes all subscriptions. After
+// Close is called, Publish returns false. It is safe to call Close
+// more than once.
+func (t *Topic[T]) Close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	subs := t.subs
+	t.subs = make(map[*Chann[T]]*subscription[T])
+	for _, s := range subs {
+		close(s.quit)
+	}
+	t.history = nil
+	t.mu.Unlock()
+
+	for _, s := range subs {
+		s.unsubscribe()
+	}
+}
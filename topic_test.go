@@ -0,0 +1,103 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+func TestTopic(t *testing.T) {
+	topic := chann.NewTopic[int](3)
+	all := topic.Subscribe(chann.Cap(-1))
+	for i := 0; i < 5; i++ {
+		topic.Publish(i)
+	}
+
+	// A late subscriber replays the last messages that satisfy its
+	// filter, up to the history size.
+	even := topic.Subscribe(
+		chann.SubscribeReplay(10),
+		chann.SubscribeFilter(func(v int) bool { return v%2 == 0 }),
+	)
+	late := topic.Subscribe(chann.SubscribeReplay(1))
+	for i := 5; i < 8; i++ {
+		topic.Publish(i)
+	}
+
+	if !topic.Unsubscribe(late) || topic.Unsubscribe(late) {
+		t.Fatalf("unexpected unsubscribe result")
+	}
+	if got, want := chann.Collect(late), []int{4, 5, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected messages, got %v want %v", got, want)
+	}
+	if topic.Len() != 2 {
+		t.Fatalf("unexpected number of subscriptions, got %v want %v", topic.Len(), 2)
+	}
+
+	topic.Close()
+	topic.Close()
+	if topic.Publish(8) {
+		t.Fatalf("publish succeeded after the topic is closed")
+	}
+	if got, want := chann.Collect(all), []int{0, 1, 2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected messages, got %v want %v", got, want)
+	}
+	if got, want := chann.Collect(even), []int{2, 4, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected messages, got %v want %v", got, want)
+	}
+	if _, ok := <-topic.Subscribe().Out(); ok {
+		t.Fatalf("subscription to a closed topic is not closed")
+	}
+}
+
+func TestTopicBlock(t *testing.T) {
+	topic := chann.NewTopic[int](3)
+	for i := 0; i < 3; i++ {
+		topic.Publish(i)
+	}
+
+	// The filter may call the Topic, and the replayed messages do not
+	// wait for the subscriber, even with the Block policy.
+	slow := topic.Subscribe(
+		chann.SubscribePolicy(chann.Block),
+		chann.SubscribeReplay(3),
+		chann.SubscribeFilter(func(v int) bool { return topic.Len() >= 0 }),
+	)
+	published := make(chan struct{})
+	go func() {
+		for i := 3; i < 6; i++ {
+			topic.Publish(i)
+		}
+		close(published)
+	}()
+
+	// Publish waits for the slow subscriber, but the other methods do
+	// not, and the subscriber can unsubscribe itself.
+	if got := <-slow.Out(); got != 0 {
+		t.Fatalf("unexpected message, got %v want 0", got)
+	}
+	fast := topic.Subscribe(chann.Cap(-1))
+	if topic.Len() != 2 {
+		t.Fatalf("unexpected number of subscriptions, got %v want %v", topic.Len(), 2)
+	}
+	if !topic.Unsubscribe(slow) {
+		t.Fatalf("unexpected unsubscribe result")
+	}
+	<-published
+	got := chann.Collect(slow)
+	if len(got) < 2 || !reflect.DeepEqual(got[:2], []int{1, 2}) {
+		t.Fatalf("unexpected messages, got %v want replayed messages first", got)
+	}
+
+	topic.Close()
+	if got, want := chann.Collect(fast), []int{3, 4, 5}; len(got) > len(want) || !reflect.DeepEqual(got, want[len(want)-len(got):]) {
+		t.Fatalf("unexpected messages, got %v want a suffix of %v", got, want)
+	}
+}
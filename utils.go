@@ -6,59 +6,216 @@
 
 package chann
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+	"sync"
+)
 
 // Ranger returns a Sender and a Receiver. The Receiver provides a
 // Next method to retrieve values. The Sender provides a Send method
 // to send values and a Close method to stop sending values. The Next
 // method indicates when the Sender has been closed, and the Send
-// method indicates when the Receiver has been freed.
+// method indicates when the Receiver has been stopped or freed.
 //
 // This is a convenient way to exit a goroutine sending values when
 // the receiver stops reading them.
+//
+// Both the Sender and the Receiver can be cloned to share the same
+// stream between multiple producers or multiple consumers.
 func Ranger[T any]() (*Sender[T], *Receiver[T]) {
-	c := New[T]()
-	d := New[bool]()
-	s := &Sender[T]{values: c, done: d}
-	r := &Receiver[T]{values: c, done: d}
-	runtime.SetFinalizer(r, func(r *Receiver[T]) { r.finalize() })
-	return s, r
+	return RangerContext[T](context.Background())
 }
 
-// A sender is used to send values to a Receiver.
-type Sender[T any] struct {
+// RangerContext is like Ranger, but the returned Receiver and all its
+// clones are stopped as soon as the given context is done.
+func RangerContext[T any](ctx context.Context) (*Sender[T], *Receiver[T]) {
+	rg := &ranger[T]{
+		values:    New[T](),
+		done:      New[bool](Cap(0)),
+		senders:   1,
+		receivers: 1,
+	}
+
+	// The watching goroutine must not refer to the receiver, otherwise
+	// the finalizer of the receiver would never run.
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				rg.stop()
+			case <-rg.done.Out():
+			}
+		}()
+	}
+	return &Sender[T]{rg: rg}, newReceiver(rg)
+}
+
+// ranger is the state shared by all senders and receivers that are
+// created from the same Ranger call.
+type ranger[T any] struct {
 	values *Chann[T]
 	done   *Chann[bool]
+	once   sync.Once
+
+	mu                 sync.Mutex
+	senders, receivers int
+	err                error
+}
+
+// stop closes the done channel, which tells all senders that no more
+// values will be received.
+func (rg *ranger[T]) stop() { rg.once.Do(rg.done.Close) }
+
+// A sender is used to send values to a Receiver.
+type Sender[T any] struct {
+	rg   *ranger[T]
+	once sync.Once
 }
 
 // Send sends a value to the receiver. It returns whether any more
 // values may be sent; if it returns false the value was not sent.
+// Once the receiver and all its clones are stopped, Send always
+// returns false.
 func (s *Sender[T]) Send(v T) bool {
 	select {
-	case s.values.In() <- v:
+	case <-s.rg.done.Out():
+		return false
+	default:
+	}
+	select {
+	case s.rg.values.In() <- v:
 		return true
-	case <-s.done.Out():
+	case <-s.rg.done.Out():
 		return false
 	}
 }
 
-// Close tells the receiver that no more values will arrive.
-// After Close is called, the Sender may no longer be used.
-func (s *Sender[T]) Close() { s.values.Close() }
+// Clone returns a new Sender that sends values to the same receivers.
+// The receivers are told that no more values will arrive only after
+// the Sender and all its clones are closed.
+//
+// Clone must not be called after Close.
+func (s *Sender[T]) Clone() *Sender[T] {
+	s.rg.mu.Lock()
+	s.rg.senders++
+	s.rg.mu.Unlock()
+	return &Sender[T]{rg: s.rg}
+}
+
+// Close tells the receiver that no more values will arrive from this
+// Sender. After Close is called, the Sender may no longer be used.
+func (s *Sender[T]) Close() { s.CloseWithError(nil) }
+
+// CloseWithError is like Close, but additionally reports the given
+// error to the receiver, which can retrieve it by Receiver.Err after
+// Next returns false. If more than one Sender is closed with a non-nil
+// error, only the first error is reported. CloseWithError with a nil
+// error is the same as Close.
+func (s *Sender[T]) CloseWithError(err error) {
+	s.once.Do(func() {
+		s.rg.mu.Lock()
+		if s.rg.err == nil {
+			s.rg.err = err
+		}
+		s.rg.senders--
+		last := s.rg.senders == 0
+		s.rg.mu.Unlock()
+		if last {
+			s.rg.values.Close()
+		}
+	})
+}
 
 // A Receiver receives values from a Sender.
 type Receiver[T any] struct {
-	values *Chann[T]
-	done   *Chann[bool]
+	rg      *ranger[T]
+	once    sync.Once
+	stopped chan struct{}
+}
+
+// newReceiver returns a new Receiver of the given ranger. The caller
+// must have accounted it in the receivers count.
+func newReceiver[T any](rg *ranger[T]) *Receiver[T] {
+	r := &Receiver[T]{rg: rg, stopped: make(chan struct{})}
+	runtime.SetFinalizer(r, func(r *Receiver[T]) { r.finalize() })
+	return r
 }
 
 // Next returns the next value from the channel. The bool result
 // indicates whether the value is valid, or whether the Sender has
-// been closed and no more values will be received.
+// been closed or the Receiver has been stopped, and no more values
+// will be received.
 func (r *Receiver[T]) Next() (T, bool) {
-	v, ok := <-r.values.Out()
-	return v, ok
+	var nilT T
+
+	select {
+	case <-r.stopped:
+		return nilT, false
+	case <-r.rg.done.Out():
+		return nilT, false
+	default:
+	}
+	select {
+	case v, ok := <-r.rg.values.Out():
+		return v, ok
+	case <-r.stopped:
+		return nilT, false
+	case <-r.rg.done.Out():
+		return nilT, false
+	}
+}
+
+// Err returns the error that a Sender was closed with by
+// CloseWithError, or nil if all senders were closed by Close.
+// It should be called after Next returns false, to tell a normal
+// end of stream from a failure.
+func (r *Receiver[T]) Err() error {
+	r.rg.mu.Lock()
+	defer r.rg.mu.Unlock()
+	return r.rg.err
+}
+
+// Clone returns a new Receiver that shares the values from the same
+// senders. Each value is received by only one of the receivers. The
+// senders are told that no more values will be received only after
+// the Receiver and all its clones are stopped or freed.
+func (r *Receiver[T]) Clone() *Receiver[T] {
+	r.rg.mu.Lock()
+	r.rg.receivers++
+	r.rg.mu.Unlock()
+	return newReceiver(r.rg)
+}
+
+// Stop tells the sender that no more values will be received by this
+// Receiver. After Stop is called, Next returns false, and once all
+// clones of the Receiver are stopped, so does Send. It is safe to call
+// Stop more than once.
+//
+// A Receiver that is never stopped is stopped when it is freed,
+// but the time of that is unpredictable.
+func (r *Receiver[T]) Stop() {
+	r.once.Do(func() {
+		close(r.stopped)
+		r.rg.mu.Lock()
+		r.rg.receivers--
+		last := r.rg.receivers == 0
+		r.rg.mu.Unlock()
+		if last {
+			r.rg.stop()
+		}
+	})
 }
 
 // finalize is a finalizer for the receiver.
-func (r *Receiver[T]) finalize() { r.done.Close() }
+func (r *Receiver[T]) finalize() { r.Stop() }
+
+// Collect receives all values from the channel until it is closed,
+// and returns them as a slice in the order of receiving.
+func Collect[T any](ch *Chann[T]) []T {
+	var s []T
+	for v := range ch.Out() {
+		s = append(s, v)
+	}
+	return s
+}
@@ -7,7 +7,10 @@
 package chann_test
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"golang.design/x/chann"
 )
@@ -25,3 +28,118 @@ func TestRanger(t *testing.T) {
 	}
 	t.Log(n)
 }
+
+func TestRangerStop(t *testing.T) {
+	s, r := chann.Ranger[int]()
+
+	if !s.Send(1) {
+		t.Fatalf("send failed before the receiver is stopped")
+	}
+	r.Stop()
+	r.Stop()
+	for i := 0; i < 100; i++ {
+		if s.Send(i) {
+			t.Fatalf("send succeeded after the receiver is stopped")
+		}
+	}
+	if _, ok := r.Next(); ok {
+		t.Fatalf("receive succeeded after the receiver is stopped")
+	}
+}
+
+func TestRangerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, r := chann.RangerContext[int](ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; s.Send(i); i++ {
+		}
+	}()
+	if _, ok := r.Next(); !ok {
+		t.Fatalf("cannot receive from sender")
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("sender was not stopped after the context is canceled")
+	}
+	if _, ok := r.Next(); ok {
+		t.Fatalf("receive succeeded after the context is canceled")
+	}
+}
+
+func TestRangerClone(t *testing.T) {
+	t.Run("receiver", func(t *testing.T) {
+		s, r1 := chann.Ranger[int]()
+		r2 := r1.Clone()
+
+		if !s.Send(1) {
+			t.Fatalf("send failed before the receivers are stopped")
+		}
+		r1.Stop()
+		if !s.Send(2) {
+			t.Fatalf("send failed before all receivers are stopped")
+		}
+		for i := 1; i <= 2; i++ {
+			if v, ok := r2.Next(); !ok || v != i {
+				t.Fatalf("unexpected receive, got %v/%v want %v/%v", v, ok, i, true)
+			}
+		}
+		r2.Stop()
+		if s.Send(3) {
+			t.Fatalf("send succeeded after all receivers are stopped")
+		}
+	})
+
+	t.Run("sender", func(t *testing.T) {
+		s1, r := chann.Ranger[int]()
+		s2 := s1.Clone()
+
+		s1.Send(1)
+		s1.Close()
+		s1.Close()
+		s2.Send(2)
+		if v, ok := r.Next(); !ok || v != 1 {
+			t.Fatalf("unexpected receive, got %v/%v want %v/%v", v, ok, 1, true)
+		}
+		if v, ok := r.Next(); !ok || v != 2 {
+			t.Fatalf("unexpected receive, got %v/%v want %v/%v", v, ok, 2, true)
+		}
+		s2.Close()
+		if _, ok := r.Next(); ok {
+			t.Fatalf("receive succeeded after all senders are closed")
+		}
+	})
+}
+
+func TestRangerCloseWithError(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	s, r := chann.Ranger[int]()
+	go func() {
+		s.Send(42)
+		s.CloseWithError(errFailed)
+	}()
+	if v, ok := r.Next(); !ok || v != 42 {
+		t.Fatalf("unexpected receive, got %v/%v want %v/%v", v, ok, 42, true)
+	}
+	if _, ok := r.Next(); ok {
+		t.Fatalf("receive succeeded after the sender is closed")
+	}
+	if err := r.Err(); err != errFailed {
+		t.Fatalf("unexpected error, got %v want %v", err, errFailed)
+	}
+
+	s, r = chann.Ranger[int]()
+	s.Close()
+	if _, ok := r.Next(); ok {
+		t.Fatalf("receive succeeded after the sender is closed")
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("unexpected error, got %v want %v", err, nil)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the time to the time-based operators, which can be
+// replaced by WithClock, for instance, with a fake clock in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer returns a new Timer that fires after the duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, which behaves as time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing.
+	Stop() bool
+	// Reset changes the Timer to fire after the duration d.
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// TumblingWindow returns a channel that receives the values received
+// from in, grouped by consecutive windows of the duration d that do
+// not overlap. Each window is sent when it ends, even if no value
+// arrives meanwhile, and empty windows are skipped.
+//
+// Once in is closed, the last partial window is sent and the returned
+// channel is closed. Once the context is done, the returned channel is
+// closed without sending the partial window. The options configure the
+// output channel, and WithClock configures the clock of the operator.
+func TumblingWindow[T any](ctx context.Context, in *Chann[T], d time.Duration, opts ...Opt) *Chann[[]T] {
+	return SlidingWindow(ctx, in, d, d, opts...)
+}
+
+// SlidingWindow returns a channel that receives the values received
+// from in, grouped by windows of the duration size that start every
+// duration slide. If slide is shorter than size, the windows overlap,
+// and a value may be sent in more than one window. Each window is sent
+// when it ends, even if no value arrives meanwhile, and empty windows
+// are skipped.
+//
+// Once in is closed, the values that have not been sent by any window
+// are sent with the window that ends at that moment, and the returned
+// channel is closed. Once the context is done, the returned channel is
+// closed without sending the partial window. The options are the same
+// as for TumblingWindow.
+func SlidingWindow[T any](ctx context.Context, in *Chann[T], size, slide time.Duration, opts ...Opt) *Chann[[]T] {
+	if size <= 0 || slide <= 0 {
+		panic("chann: non-positive window duration")
+	}
+	out, clock := newOutput[[]T](opts)
+
+	type entry struct {
+		t time.Time
+		v T
+	}
+	go func() {
+		defer out.Close()
+
+		var (
+			entries []entry
+			unsent  int // number of entries not sent by any window
+		)
+		// window returns the values of the window that ends at the
+		// given time, which is closed at its start and open at its
+		// end, and drops the values that will not belong to any
+		// further window.
+		window := func(end time.Time) []T {
+			start := end.Add(-size)
+			for len(entries) > 0 && entries[0].t.Before(start) {
+				entries = entries[1:]
+			}
+			var vs []T
+			for _, e := range entries {
+				if !e.t.Before(end) {
+					break
+				}
+				vs = append(vs, e.v)
+			}
+			return vs
+		}
+		emit := func(vs []T) bool {
+			select {
+			case out.In() <- vs:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// The windows end at fixed times, regardless of when the
+		// timer actually fires.
+		end := clock.Now().Add(slide)
+		timer := clock.NewTimer(slide)
+		defer timer.Stop()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if unsent > 0 {
+						vs := make([]T, unsent)
+						for i, e := range entries[len(entries)-unsent:] {
+							vs[i] = e.v
+						}
+						emit(vs)
+					}
+					return
+				}
+				entries = append(entries, entry{t: clock.Now(), v: v})
+				unsent++
+			case <-timer.C():
+				vs := window(end)
+				end = end.Add(slide)
+				timer.Reset(end.Sub(clock.Now()))
+				unsent = len(entries) - len(vs)
+				if len(vs) > 0 && !emit(vs) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// SessionWindow returns a channel that receives the values received
+// from in, grouped by sessions. A session starts with a value, and ends
+// once no value arrives for the duration gap. Each session is sent when
+// it ends.
+//
+// Once in is closed, the last session is sent and the returned channel
+// is closed. Once the context is done, the returned channel is closed
+// without sending the last session. The options are the same as for
+// TumblingWindow.
+func SessionWindow[T any](ctx context.Context, in *Chann[T], gap time.Duration, opts ...Opt) *Chann[[]T] {
+	if gap <= 0 {
+		panic("chann: non-positive window duration")
+	}
+	out, clock := newOutput[[]T](opts)
+	go func() {
+		defer out.Close()
+
+		var (
+			session []T
+			timer   Timer
+			expired <-chan time.Time
+		)
+		emit := func() bool {
+			vs := session
+			session = nil
+			select {
+			case out.In() <- vs:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case v, ok := <-in.Out():
+				if !ok {
+					if len(session) > 0 {
+						emit()
+					}
+					return
+				}
+				session = append(session, v)
+				if timer == nil {
+					timer = clock.NewTimer(gap)
+				} else {
+					resetTimer(timer, expired != nil, gap)
+				}
+				expired = timer.C()
+			case <-expired:
+				expired = nil
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.design/x/chann"
+)
+
+// fakeClock is a Clock whose time only moves by Advance. It counts the
+// calls of Now, NewTimer and Reset, so that a test can wait for an
+// operator to observe an event before moving the time.
+type fakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	calls  int
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c      *fakeClock
+	ch     chan time.Time
+	at     time.Time
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{now: time.Unix(0, 0)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.called()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) chann.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.called()
+	t := &fakeTimer{c: c, ch: make(chan time.Time, 1), at: c.now.Add(d), active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the time forward, and fires the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+// Wait waits until the clock has been called n times in total.
+func (c *fakeClock) Wait(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.calls < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *fakeClock) called() {
+	c.calls++
+	c.cond.Broadcast()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.c.called()
+	active := t.active
+	t.at, t.active = t.c.now.Add(d), true
+	return active
+}
+
+func expectWindow(t *testing.T, out *chann.Chann[[]int], want []int) {
+	t.Helper()
+	if got, ok := <-out.Out(); !ok || !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected window, got %v/%v want %v", got, ok, want)
+	}
+}
+
+func expectClosed[T any](t *testing.T, out *chann.Chann[T]) {
+	t.Helper()
+	if got, ok := <-out.Out(); ok {
+		t.Fatalf("output is not closed, got %v", got)
+	}
+}
+
+func TestTumblingWindow(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.TumblingWindow(context.Background(), in, time.Second, chann.WithClock(clock))
+	clock.Wait(2) // Now and NewTimer
+
+	in.In() <- 1
+	in.In() <- 2
+	clock.Wait(4) // Now for each value
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{1, 2})
+
+	in.In() <- 3
+	clock.Wait(7) // Now and Reset for the tick, and Now for the value
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{3})
+
+	// The partial window is sent once the input is closed.
+	in.In() <- 4
+	in.Close()
+	expectWindow(t, out, []int{4})
+	expectClosed(t, out)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.SlidingWindow(context.Background(), in, 2*time.Second, time.Second, chann.WithClock(clock))
+	clock.Wait(2) // Now and NewTimer
+
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- 1
+	clock.Wait(3) // Now for the value
+	clock.Advance(500 * time.Millisecond)
+	expectWindow(t, out, []int{1})
+
+	in.In() <- 2
+	clock.Wait(6) // Now and Reset for the tick, and Now for the value
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{1, 2})
+	clock.Wait(8) // Now and Reset for the tick
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{2})
+
+	in.Close()
+	expectClosed(t, out)
+}
+
+func TestSessionWindow(t *testing.T) {
+	clock := newFakeClock()
+	in := chann.New[int](chann.Cap(0))
+	out := chann.SessionWindow(context.Background(), in, time.Second, chann.WithClock(clock))
+
+	in.In() <- 1
+	clock.Wait(1) // NewTimer
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- 2
+	clock.Wait(2) // Reset
+	clock.Advance(500 * time.Millisecond)
+	in.In() <- 3
+	clock.Wait(3) // Reset
+	clock.Advance(time.Second)
+	expectWindow(t, out, []int{1, 2, 3})
+
+	in.In() <- 4
+	in.Close()
+	expectWindow(t, out, []int{4})
+	expectClosed(t, out)
+}
+
+func TestWindowRealClock(t *testing.T) {
+	in := chann.New[int](chann.Cap(0))
+	out := chann.TumblingWindow(context.Background(), in, 10*time.Millisecond)
+	in.In() <- 1
+
+	// The window is sent on time although no more value arrives.
+	select {
+	case vs := <-out.Out():
+		if !reflect.DeepEqual(vs, []int{1}) {
+			t.Fatalf("unexpected window, got %v want %v", vs, []int{1})
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("window is not sent on time")
+	}
+	in.Close()
+	expectClosed(t, out)
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann
+
+import (
+	"context"
+	"reflect"
+)
+
+// Pair is a pair of values of possibly different types.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip returns a channel that receives pairs of the values received
+// from a and b, where the n-th pair holds the n-th value of each. The
+// returned channel is closed once either a or b is closed, and a value
+// received from the other meanwhile is dropped.
+//
+// Once the context is done, the returned channel is closed. The options
+// configure the output channel as for the stream operators.
+func Zip[A, B any](ctx context.Context, a *Chann[A], b *Chann[B], opts ...Opt) *Chann[Pair[A, B]] {
+	return joinPair(ctx, a, b, false, opts)
+}
+
+// CombineLatest returns a channel that receives a pair of the latest
+// values received from a and b, every time either receives a value,
+// once both have received one. The returned channel is closed once
+// both a and b are closed, and the latest value of a closed channel
+// keeps being paired with the values of the other.
+//
+// Once the context is done, the returned channel is closed. The options
+// configure the output channel as for the stream operators.
+func CombineLatest[A, B any](ctx context.Context, a *Chann[A], b *Chann[B], opts ...Opt) *Chann[Pair[A, B]] {
+	return joinPair(ctx, a, b, true, opts)
+}
+
+// ZipN is like Zip, but for any number of channels of the same type.
+// The returned channel receives the tuples of the values received from
+// the channels, in the order of the channels.
+func ZipN[T any](ctx context.Context, ins []*Chann[T], opts ...Opt) *Chann[[]T] {
+	return joinN(ctx, ins, false, opts)
+}
+
+// CombineLatestN is like CombineLatest, but for any number of channels
+// of the same type. The returned channel receives the tuples of the
+// latest values received from the channels, in the order of the
+// channels.
+func CombineLatestN[T any](ctx context.Context, ins []*Chann[T], opts ...Opt) *Chann[[]T] {
+	return joinN(ctx, ins, true, opts)
+}
+
+func joinPair[A, B any](ctx context.Context, a *Chann[A], b *Chann[B], latest bool, opts []Opt) *Chann[Pair[A, B]] {
+	chans := []reflect.Value{reflect.ValueOf(a.Out()), reflect.ValueOf(b.Out())}
+	return join(ctx, chans, latest, opts, func(vs []reflect.Value) Pair[A, B] {
+		return Pair[A, B]{First: valueOf[A](vs[0]), Second: valueOf[B](vs[1])}
+	})
+}
+
+func joinN[T any](ctx context.Context, ins []*Chann[T], latest bool, opts []Opt) *Chann[[]T] {
+	chans := make([]reflect.Value, len(ins))
+	for i, in := range ins {
+		chans[i] = reflect.ValueOf(in.Out())
+	}
+	return join(ctx, chans, latest, opts, func(vs []reflect.Value) []T {
+		tuple := make([]T, len(vs))
+		for i, v := range vs {
+			tuple[i] = valueOf[T](v)
+		}
+		return tuple
+	})
+}
+
+// join starts a goroutine that receives from the given channels, and
+// sends a tuple of values built by tuple to the returned channel. If
+// latest is false, the goroutine zips the channels: it waits for a
+// value from every channel before sending a tuple, and stops once any
+// channel is closed. Otherwise, it combines the latest values of the
+// channels: it sends a tuple whenever a channel receives a value, once
+// every channel has received one, and stops once all channels are
+// closed. The returned channel is closed when the goroutine ends, at
+// once if there is no channel.
+func join[R any](ctx context.Context, chans []reflect.Value, latest bool, opts []Opt, tuple func([]reflect.Value) R) *Chann[R] {
+	if len(opts) == 0 {
+		opts = []Opt{Cap(0)}
+	}
+	out := New[R](opts...)
+
+	cases := make([]reflect.SelectCase, len(chans), len(chans)+1)
+	for i, ch := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: ch}
+	}
+	if done := ctx.Done(); done != nil {
+		cases = append(cases, recvCase(done))
+	}
+	go func() {
+		defer out.Close()
+		if len(chans) == 0 {
+			return
+		}
+
+		var (
+			vs       = make([]reflect.Value, len(chans))
+			received int // number of channels with a value in vs
+			closed   int // number of closed channels
+		)
+		for {
+			i, v, ok := reflect.Select(cases)
+			if i == len(chans) {
+				return
+			}
+			if !ok {
+				if !latest {
+					return
+				}
+				cases[i].Chan = reflect.Value{}
+				if closed++; closed == len(chans) {
+					return
+				}
+				continue
+			}
+			if !vs[i].IsValid() {
+				received++
+			}
+			vs[i] = v
+			if !latest {
+				// Each channel is disabled until the tuple is complete.
+				cases[i].Chan = reflect.Value{}
+			}
+			if received < len(chans) {
+				continue
+			}
+			select {
+			case out.In() <- tuple(vs):
+			case <-ctx.Done():
+				return
+			}
+			if !latest {
+				for i, ch := range chans {
+					vs[i] = reflect.Value{}
+					cases[i].Chan = ch
+				}
+				received = 0
+			}
+		}
+	}()
+	return out
+}
+
+// valueOf returns the value held by v as a T. If T is an interface
+// type and v holds nil, valueOf returns the nil value.
+func valueOf[T any](v reflect.Value) T {
+	t, _ := v.Interface().(T)
+	return t
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The golang.design Initiative Authors.
+// All rights reserved. Use of this source code is governed
+// by a MIT license that can be found in the LICENSE file.
+//
+// Written by Changkun Ou <changkun.de>
+
+package chann_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"golang.design/x/chann"
+)
+
+func TestZip(t *testing.T) {
+	a := chann.New[int]()
+	b := chann.New[string]()
+	out := chann.Zip(context.Background(), a, b)
+	for i := 0; i < 3; i++ {
+		a.In() <- i
+	}
+	a.Close()
+	for _, s := range []string{"a", "b", "c", "d"} {
+		b.In() <- s
+	}
+
+	want := []chann.Pair[int, string]{{0, "a"}, {1, "b"}, {2, "c"}}
+	if got := chann.Collect(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pairs, got %v want %v", got, want)
+	}
+}
+
+func TestCombineLatest(t *testing.T) {
+	a := chann.New[int](chann.Cap(0))
+	b := chann.New[string](chann.Cap(0))
+	out := chann.CombineLatest(context.Background(), a, b)
+	expect := func(want chann.Pair[int, string]) {
+		t.Helper()
+		if got := <-out.Out(); got != want {
+			t.Fatalf("unexpected pair, got %v want %v", got, want)
+		}
+	}
+
+	a.In() <- 1
+	a.In() <- 2
+	b.In() <- "a"
+	expect(chann.Pair[int, string]{2, "a"})
+	b.In() <- "b"
+	expect(chann.Pair[int, string]{2, "b"})
+
+	// The latest value of a closed channel is still combined.
+	a.Close()
+	b.In() <- "c"
+	expect(chann.Pair[int, string]{2, "c"})
+	b.Close()
+	expectClosed(t, out)
+}
+
+func TestZipN(t *testing.T) {
+	ins := []*chann.Chann[int]{chann.New[int](), chann.New[int](), chann.New[int]()}
+	for i, in := range ins {
+		for j := 0; j < 3+i; j++ {
+			in.In() <- 10*i + j
+		}
+		in.Close()
+	}
+
+	out := chann.ZipN(context.Background(), ins)
+	want := [][]int{{0, 10, 20}, {1, 11, 21}, {2, 12, 22}}
+	if got := chann.Collect(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tuples, got %v want %v", got, want)
+	}
+
+	expectClosed(t, chann.ZipN[int](context.Background(), nil))
+}
+
+func TestCombineLatestN(t *testing.T) {
+	ins := []*chann.Chann[int]{chann.New[int](chann.Cap(0)), chann.New[int](chann.Cap(0))}
+	out := chann.CombineLatestN(context.Background(), ins)
+
+	ins[0].In() <- 1
+	ins[1].In() <- 2
+	expectWindow(t, out, []int{1, 2})
+	ins[0].In() <- 3
+	expectWindow(t, out, []int{3, 2})
+	ins[0].Close()
+	ins[1].Close()
+	expectClosed(t, out)
+}
+
+func TestZipContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := chann.New[int]()
+	b := chann.New[int]()
+	out := chann.Zip(ctx, a, b)
+	a.In() <- 1
+	cancel()
+	expectClosed(t, out)
+}